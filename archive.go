@@ -0,0 +1,427 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/moiseevv/ginFileServer/backends"
+)
+
+// archiveKind — формат архива, распознанный по имени файла.
+type archiveKind int
+
+const (
+	archiveNone archiveKind = iota
+	archiveZip
+	archiveTar
+	archiveTarGz
+	archiveTarBz2
+)
+
+// maxExtractedTotalSize — предел на суммарный распакованный объём одного
+// архива. Размер самого архива уже ограничен http.MaxBytesReader на
+// /upload, но разжатый объём — нет, поэтому без отдельного предела
+// небольшой zip/gzip-бомбой можно исчерпать диск.
+const maxExtractedTotalSize = 500 << 20
+
+// detectArchiveKind определяет формат архива по расширению имени файла.
+func detectArchiveKind(filename string) archiveKind {
+	lower := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz
+	case strings.HasSuffix(lower, ".tar.bz2"):
+		return archiveTarBz2
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar
+	default:
+		return archiveNone
+	}
+}
+
+// extractedMember — один файл, извлечённый из архива и сохранённый в backend.
+type extractedMember struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// extractOptions — те же правила, что действуют при обычной загрузке
+// (MIME allow/deny, резерв квоты ключа, delete-key и expiry), применённые к
+// каждой записи архива в отдельности, плюс общий предел на распакованный
+// объём. deleteKey и expiry едины для всех членов одного архива — так же,
+// как один /upload выдаёт один ключ удаления на один сохранённый файл.
+type extractOptions struct {
+	allowMime, denyMime []string
+	reserve             func(int64) bool
+	release             func(int64)
+	deleteKey           string
+	expiry              *time.Time
+}
+
+// sanitizeArchiveEntryName проверяет путь записи архива на zip-slip: пути,
+// которые после нормализации оказываются абсолютными или выходят за пределы
+// корня через "..", отклоняются, а не обрезаются, чтобы не подменить
+// вредоносное имя на угадываемое.
+func sanitizeArchiveEntryName(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if cleaned == "." || cleaned == "" {
+		return "", fmt.Errorf("пустое имя записи в архиве")
+	}
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("запись архива %q выходит за пределы директории загрузки", name)
+	}
+	return cleaned, nil
+}
+
+// archiveMemberKey строит ключ backend'а для извлечённого файла. Backend'ы не
+// поддерживают подкаталоги (см. backends.FileInfo), поэтому вложенные пути
+// внутри архива сплющиваются в одно имя через "_".
+func archiveMemberKey(prefix, entryPath string) string {
+	return prefix + "_" + strings.ReplaceAll(entryPath, "/", "_")
+}
+
+// reserveExtractedSize резервирует size байт под квоту ключа (если она есть)
+// и учитывает их в общем пределе на распакованный объём архива.
+func reserveExtractedSize(opts extractOptions, totalSize *int64, size int64) error {
+	if *totalSize+size > maxExtractedTotalSize {
+		return fmt.Errorf("архив распаковывается в более чем %d МБ, отклонено", maxExtractedTotalSize>>20)
+	}
+	if opts.reserve != nil && !opts.reserve(size) {
+		return fmt.Errorf("превышена квота на объём хранимых файлов для этого ключа")
+	}
+	*totalSize += size
+	return nil
+}
+
+// rollbackExtractedMembers удаляет из backend'а записи архива, уже успешно
+// сохранённые до того, как распаковка прервалась ошибкой на одной из
+// последующих записей, и возвращает их объём в квоту ключа. Без этого
+// частично распакованный архив навсегда оседает в хранилище: клиент получает
+// только текст ошибки, а delete_key для уже сохранённых файлов ему не
+// известен.
+func rollbackExtractedMembers(backend backends.Backend, members []extractedMember, opts extractOptions) {
+	for _, m := range members {
+		backend.Delete(m.Name)
+		if opts.release != nil {
+			opts.release(m.Size)
+		}
+	}
+}
+
+// extractZip распаковывает zip-архив (ra, size — тело загруженного файла,
+// archive/zip требует io.ReaderAt с известным размером) в backend, сохраняя
+// каждую запись под отдельным ключом с префиксом prefix. К каждой записи
+// применяются те же MIME allow/deny правила и квота, что и к обычной
+// загрузке. Если распаковка прерывается ошибкой на одной из записей, уже
+// сохранённые до неё записи откатываются (см. rollbackExtractedMembers), а
+// не остаются в backend без delete_key в ответе клиенту.
+func extractZip(ra io.ReaderAt, size int64, backend backends.Backend, prefix string, opts extractOptions) (members []extractedMember, err error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть zip-архив: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			rollbackExtractedMembers(backend, members, opts)
+		}
+	}()
+
+	var totalSize int64
+	seenKeys := make(map[string]bool)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		name, nameErr := sanitizeArchiveEntryName(f.Name)
+		if nameErr != nil {
+			err = nameErr
+			return members, err
+		}
+
+		key := archiveMemberKey(prefix, name)
+		if seenKeys[key] {
+			err = fmt.Errorf("записи архива %q и другая сворачиваются в один и тот же файл %q", f.Name, key)
+			return members, err
+		}
+
+		entrySize := int64(f.UncompressedSize64)
+		if reserveErr := reserveExtractedSize(opts, &totalSize, entrySize); reserveErr != nil {
+			err = reserveErr
+			return members, err
+		}
+
+		rc, openErr := f.Open()
+		if openErr != nil {
+			if opts.release != nil {
+				opts.release(entrySize)
+			}
+			err = fmt.Errorf("не удалось прочитать запись %q: %w", f.Name, openErr)
+			return members, err
+		}
+
+		detected, body, sniffErr := sniffContentType(rc)
+		if sniffErr != nil {
+			rc.Close()
+			if opts.release != nil {
+				opts.release(entrySize)
+			}
+			err = fmt.Errorf("не удалось определить тип содержимого %q: %w", f.Name, sniffErr)
+			return members, err
+		}
+		if reason := validateMime(name, detected, opts.allowMime, opts.denyMime); reason != "" {
+			rc.Close()
+			if opts.release != nil {
+				opts.release(entrySize)
+			}
+			err = fmt.Errorf("запись %q: %s", f.Name, reason)
+			return members, err
+		}
+
+		putErr := backend.Put(key, body, entrySize, backends.PutOptions{MimeType: detected, DeleteKey: opts.deleteKey, Expiry: opts.expiry})
+		rc.Close()
+		if putErr != nil {
+			if opts.release != nil {
+				opts.release(entrySize)
+			}
+			err = fmt.Errorf("не удалось сохранить %q: %w", key, putErr)
+			return members, err
+		}
+
+		seenKeys[key] = true
+		members = append(members, extractedMember{Name: key, Size: entrySize})
+	}
+	return members, nil
+}
+
+// archiveTarReader оборачивает r в tar.Reader, предварительно распаковывая
+// gzip/bzip2, если того требует kind.
+func archiveTarReader(kind archiveKind, r io.Reader) (*tar.Reader, error) {
+	switch kind {
+	case archiveTar:
+		return tar.NewReader(r), nil
+	case archiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть gzip-поток: %w", err)
+		}
+		return tar.NewReader(gz), nil
+	case archiveTarBz2:
+		return tar.NewReader(bzip2.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат tar-архива")
+	}
+}
+
+// extractTar распаковывает tar-архив (уже обёрнутый archiveTarReader) в
+// backend, сохраняя каждую запись под отдельным ключом с префиксом prefix.
+// К каждой записи применяются те же MIME allow/deny правила и квота, что и
+// к обычной загрузке. Если распаковка прерывается ошибкой на одной из
+// записей, уже сохранённые до неё записи откатываются (см.
+// rollbackExtractedMembers), а не остаются в backend без delete_key в ответе
+// клиенту.
+func extractTar(tr *tar.Reader, backend backends.Backend, prefix string, opts extractOptions) (members []extractedMember, err error) {
+	defer func() {
+		if err != nil {
+			rollbackExtractedMembers(backend, members, opts)
+		}
+	}()
+
+	var totalSize int64
+	seenKeys := make(map[string]bool)
+	for {
+		hdr, nextErr := tr.Next()
+		if nextErr == io.EOF {
+			break
+		}
+		if nextErr != nil {
+			err = fmt.Errorf("не удалось прочитать tar-архив: %w", nextErr)
+			return members, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, nameErr := sanitizeArchiveEntryName(hdr.Name)
+		if nameErr != nil {
+			err = nameErr
+			return members, err
+		}
+
+		key := archiveMemberKey(prefix, name)
+		if seenKeys[key] {
+			err = fmt.Errorf("записи архива %q и другая сворачиваются в один и тот же файл %q", hdr.Name, key)
+			return members, err
+		}
+
+		if reserveErr := reserveExtractedSize(opts, &totalSize, hdr.Size); reserveErr != nil {
+			err = reserveErr
+			return members, err
+		}
+
+		detected, body, sniffErr := sniffContentType(tr)
+		if sniffErr != nil {
+			if opts.release != nil {
+				opts.release(hdr.Size)
+			}
+			err = fmt.Errorf("не удалось определить тип содержимого %q: %w", hdr.Name, sniffErr)
+			return members, err
+		}
+		if reason := validateMime(name, detected, opts.allowMime, opts.denyMime); reason != "" {
+			if opts.release != nil {
+				opts.release(hdr.Size)
+			}
+			err = fmt.Errorf("запись %q: %s", hdr.Name, reason)
+			return members, err
+		}
+
+		if putErr := backend.Put(key, body, hdr.Size, backends.PutOptions{MimeType: detected, DeleteKey: opts.deleteKey, Expiry: opts.expiry}); putErr != nil {
+			if opts.release != nil {
+				opts.release(hdr.Size)
+			}
+			err = fmt.Errorf("не удалось сохранить %q: %w", key, putErr)
+			return members, err
+		}
+
+		seenKeys[key] = true
+		members = append(members, extractedMember{Name: key, Size: hdr.Size})
+	}
+	return members, nil
+}
+
+// firstMissingArchiveFile проверяет существование каждого запрошенного файла
+// через backend.Exists до того, как GET /archive отправит статус 200 и
+// начнёт стриминг: иначе отсутствующее имя обнаруживается уже во время
+// сборки архива, а клиент к этому моменту получил "успешный" ответ и видит
+// лишь оборванный zip/tar.gz без какой-либо диагностики. Возвращает имя
+// первого отсутствующего файла (пустая строка — все найдены).
+func firstMissingArchiveFile(backend backends.Backend, names []string) (string, error) {
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		sanitized, err := sanitizeRequestedFileName(name)
+		if err != nil {
+			return "", err
+		}
+		exists, err := backend.Exists(sanitized)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return sanitized, nil
+		}
+	}
+	return "", nil
+}
+
+// sanitizeRequestedFileName проверяет имя файла, запрошенное через
+// GET /archive?files=...: ключи backend'а — это плоское пространство имён
+// без подкаталогов (см. archiveMemberKey), поэтому имя с "/", "\" или ".."
+// не может быть настоящим сохранённым файлом и отклоняется, чтобы не
+// прочитать через backend.Get что-то за пределами хранилища.
+func sanitizeRequestedFileName(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "/\\") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("некорректное имя файла: %q", name)
+	}
+	return name, nil
+}
+
+// buildZipArchive пишет в w zip-архив из перечисленных файлов backend'а,
+// читая их по одному, чтобы память не зависела от суммарного размера.
+func buildZipArchive(w io.Writer, backend backends.Backend, names []string) error {
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := addFileToZip(zw, backend, name); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func addFileToZip(zw *zip.Writer, backend backends.Backend, name string) error {
+	name, err := sanitizeRequestedFileName(name)
+	if err != nil {
+		return err
+	}
+
+	rc, meta, err := backend.Get(name)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать %q: %w", name, err)
+	}
+	defer rc.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: meta.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, rc)
+	return err
+}
+
+// buildTarGzArchive пишет в w tar.gz-архив из перечисленных файлов backend'а,
+// читая их по одному, чтобы память не зависела от суммарного размера.
+func buildTarGzArchive(w io.Writer, backend backends.Backend, names []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if err := addFileToTar(tw, backend, name); err != nil {
+			tw.Close()
+			gz.Close()
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+func addFileToTar(tw *tar.Writer, backend backends.Backend, name string) error {
+	name, err := sanitizeRequestedFileName(name)
+	if err != nil {
+		return err
+	}
+
+	rc, meta, err := backend.Get(name)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать %q: %w", name, err)
+	}
+	defer rc.Close()
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Size:    meta.Size,
+		Mode:    0o644,
+		ModTime: meta.ModTime,
+	}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, rc)
+	return err
+}
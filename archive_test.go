@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/moiseevv/ginFileServer/backends"
+)
+
+// fakeBackend — минимальная реализация backends.Backend в памяти, для тестов
+// archive.go, которым не нужен настоящий backend, а нужна возможность
+// проверить, что именно было сохранено/удалено.
+type fakeBackend struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{files: make(map[string][]byte)}
+}
+
+func (b *fakeBackend) Put(key string, r io.Reader, size int64, opts backends.PutOptions) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.files[key] = data
+	return nil
+}
+
+func (b *fakeBackend) Get(key string) (io.ReadCloser, backends.Metadata, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.files[key]
+	if !ok {
+		return nil, backends.Metadata{}, backends.ErrNotExist
+	}
+	return io.NopCloser(bytes.NewReader(data)), backends.Metadata{Size: int64(len(data))}, nil
+}
+
+func (b *fakeBackend) Delete(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.files, key)
+	return nil
+}
+
+func (b *fakeBackend) List() ([]backends.FileInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	files := make([]backends.FileInfo, 0, len(b.files))
+	for k, v := range b.files {
+		files = append(files, backends.FileInfo{Key: k, Size: int64(len(v))})
+	}
+	return files, nil
+}
+
+func (b *fakeBackend) Exists(key string) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.files[key]
+	return ok, nil
+}
+
+func (b *fakeBackend) keys() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	keys := make([]string, 0, len(b.files))
+	for k := range b.files {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestExtractZip_RejectsZipSlipAndRollsBack(t *testing.T) {
+	// "good.txt" должен успеть сохраниться до того, как распаковка дойдёт до
+	// вредоносной записи: extractZip обходит срез zr.File, в котором записи
+	// идут в порядке добавления в архив, поэтому порядок ниже детерминирован.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	mustWrite := func(name, content string) {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("не удалось создать запись %q: %v", name, err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("не удалось записать содержимое %q: %v", name, err)
+		}
+	}
+	mustWrite("good.txt", "безобидное содержимое")
+	mustWrite("../../etc/passwd", "root:x:0:0:root:/root:/bin/bash")
+	if err := zw.Close(); err != nil {
+		t.Fatalf("не удалось закрыть zip: %v", err)
+	}
+
+	backend := newFakeBackend()
+	var reserved int64
+	opts := extractOptions{
+		reserve: func(n int64) bool { reserved += n; return true },
+		release: func(n int64) { reserved -= n },
+	}
+
+	ra := bytes.NewReader(buf.Bytes())
+	_, err := extractZip(ra, int64(buf.Len()), backend, "upload1", opts)
+	if err == nil {
+		t.Fatal("ожидали ошибку на записи архива с выходом за пределы директории (zip-slip)")
+	}
+	if keys := backend.keys(); len(keys) != 0 {
+		t.Fatalf("ожидали, что rollback удалит уже сохранённые записи, в backend остались: %v", keys)
+	}
+	if reserved != 0 {
+		t.Fatalf("ожидали, что rollback освободит зарезервированную квоту, осталось: %d", reserved)
+	}
+}
+
+func TestExtractTar_RejectsZipSlipAndRollsBack(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	mustWrite := func(name, content string) {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Size: int64(len(content)), Mode: 0o644}); err != nil {
+			t.Fatalf("не удалось записать заголовок %q: %v", name, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("не удалось записать содержимое %q: %v", name, err)
+		}
+	}
+	mustWrite("good.txt", "безобидное содержимое")
+	mustWrite("../../etc/passwd", "root:x:0:0:root:/root:/bin/bash")
+	if err := tw.Close(); err != nil {
+		t.Fatalf("не удалось закрыть tar: %v", err)
+	}
+
+	backend := newFakeBackend()
+	var reserved int64
+	opts := extractOptions{
+		reserve: func(n int64) bool { reserved += n; return true },
+		release: func(n int64) { reserved -= n },
+	}
+
+	_, err := extractTar(tar.NewReader(bytes.NewReader(buf.Bytes())), backend, "upload1", opts)
+	if err == nil {
+		t.Fatal("ожидали ошибку на записи архива с выходом за пределы директории (zip-slip)")
+	}
+	if keys := backend.keys(); len(keys) != 0 {
+		t.Fatalf("ожидали, что rollback удалит уже сохранённые записи, в backend остались: %v", keys)
+	}
+	if reserved != 0 {
+		t.Fatalf("ожидали, что rollback освободит зарезервированную квоту, осталось: %d", reserved)
+	}
+}
+
+// zeroReader отдаёт remaining нулевых байт, затем io.EOF — синтетический источник большого
+// файла, не требующий выделения памяти под его содержимое целиком.
+type zeroReader struct{ remaining int64 }
+
+func (r *zeroReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	for i := range p {
+		p[i] = 0
+	}
+	r.remaining -= int64(len(p))
+	return len(p), nil
+}
+
+// trackingReader оборачивает zeroReader, запоминая суммарно прочитанный объём
+// и наибольший размер одного Read-вызова — этого достаточно, чтобы отличить
+// стриминг (много вызовов с небольшим буфером) от буферизации всего файла в
+// памяти одним io.ReadAll (один вызов на весь объём).
+type trackingReader struct {
+	src      io.Reader
+	total    int64
+	maxChunk int
+}
+
+func (r *trackingReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	r.total += int64(n)
+	if n > r.maxChunk {
+		r.maxChunk = n
+	}
+	return n, err
+}
+
+// largeFileBackend отдаёт из Get синтетический поток заданного размера, не
+// храня его в памяти — нужен, чтобы проверить, что buildZipArchive/
+// buildTarGzArchive стримят файлы, а не буферизуют их целиком.
+type largeFileBackend struct {
+	size    int64
+	lastGet *trackingReader
+}
+
+func (b *largeFileBackend) Put(key string, r io.Reader, size int64, opts backends.PutOptions) error {
+	return nil
+}
+
+func (b *largeFileBackend) Get(key string) (io.ReadCloser, backends.Metadata, error) {
+	b.lastGet = &trackingReader{src: &zeroReader{remaining: b.size}}
+	return io.NopCloser(b.lastGet), backends.Metadata{Size: b.size}, nil
+}
+
+func (b *largeFileBackend) Delete(key string) error            { return nil }
+func (b *largeFileBackend) List() ([]backends.FileInfo, error) { return nil, nil }
+func (b *largeFileBackend) Exists(key string) (bool, error)    { return true, nil }
+
+// maxStreamingChunk — верхняя граница на размер одного Read-вызова, с запасом
+// выше буфера io.Copy (32 КБ) и zip.Writer'а, но на порядки меньше
+// fileSize — её превышение означает, что файл был прочитан одним большим
+// куском (например через io.ReadAll), а не потоково.
+const maxStreamingChunk = 4 << 20 // 4 МБ
+
+// TestBuildZipArchive_StreamsWithoutBufferingWholeFile загружает в архив файл
+// размером больше, чем этому тесту разумно было бы выделить под буфер,
+// проверяя, что buildZipArchive действительно читает его у backend'а малыми
+// кусками (как и задокументировано), а не целиком в память.
+func TestBuildZipArchive_StreamsWithoutBufferingWholeFile(t *testing.T) {
+	const fileSize = 256 << 20 // 256 МБ — заведомо больше разумного буфера
+	backend := &largeFileBackend{size: fileSize}
+
+	if err := buildZipArchive(io.Discard, backend, []string{"big.bin"}); err != nil {
+		t.Fatalf("buildZipArchive вернул ошибку: %v", err)
+	}
+	if backend.lastGet.total != fileSize {
+		t.Fatalf("ожидали, что будет прочитано %d байт исходных данных, прочитано: %d", fileSize, backend.lastGet.total)
+	}
+	if backend.lastGet.maxChunk > maxStreamingChunk {
+		t.Fatalf("Get читался куском в %d байт — похоже на буферизацию всего файла, а не на стриминг", backend.lastGet.maxChunk)
+	}
+}
+
+func TestBuildTarGzArchive_StreamsWithoutBufferingWholeFile(t *testing.T) {
+	const fileSize = 256 << 20
+	backend := &largeFileBackend{size: fileSize}
+
+	if err := buildTarGzArchive(io.Discard, backend, []string{"big.bin"}); err != nil {
+		t.Fatalf("buildTarGzArchive вернул ошибку: %v", err)
+	}
+	if backend.lastGet.total != fileSize {
+		t.Fatalf("ожидали, что будет прочитано %d байт исходных данных, прочитано: %d", fileSize, backend.lastGet.total)
+	}
+	if backend.lastGet.maxChunk > maxStreamingChunk {
+		t.Fatalf("Get читался куском в %d байт — похоже на буферизацию всего файла, а не на стриминг", backend.lastGet.maxChunk)
+	}
+}
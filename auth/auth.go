@@ -0,0 +1,291 @@
+// Package auth реализует Gin middleware для авторизации запросов: статичные
+// API-ключи через заголовок Authorization: Bearer <key> (с областями доступа
+// и квотами) и HTTP Basic — для формы загрузки в браузере.
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Scope — одна из областей доступа, которыми можно наделить API-ключ.
+type Scope string
+
+const (
+	ScopeUpload   Scope = "upload"
+	ScopeDownload Scope = "download"
+	ScopeList     Scope = "list"
+	ScopeDelete   Scope = "delete"
+	ScopeAdmin    Scope = "admin"
+)
+
+// Quota — необязательные ограничения, привязанные к ключу.
+type Quota struct {
+	MaxBytesStored    int64 `json:"max_bytes_stored,omitempty" yaml:"max_bytes_stored,omitempty"`
+	MaxRequestsPerMin int   `json:"max_requests_per_min,omitempty" yaml:"max_requests_per_min,omitempty"`
+}
+
+// KeyConfig — одна запись ключа в конфигурационном файле.
+type KeyConfig struct {
+	Key    string  `json:"key" yaml:"key"`
+	Scopes []Scope `json:"scopes" yaml:"scopes"`
+	Quota  Quota   `json:"quota,omitempty" yaml:"quota,omitempty"`
+}
+
+// Config — содержимое файла с ключами (JSON или YAML, определяется по
+// расширению в LoadConfig). BasicAuthUser/BasicAuthPass — необязательная
+// пара логин/пароль для формы в браузере; при успешной Basic-авторизации
+// выдаются все области доступа.
+type Config struct {
+	Keys           []KeyConfig `json:"keys" yaml:"keys"`
+	BasicAuthUser  string      `json:"basic_auth_user,omitempty" yaml:"basic_auth_user,omitempty"`
+	BasicAuthPass  string      `json:"basic_auth_pass,omitempty" yaml:"basic_auth_pass,omitempty"`
+	PublicDownload bool        `json:"public_download,omitempty" yaml:"public_download,omitempty"`
+}
+
+// LoadConfig читает файл с ключами. Формат определяется по расширению:
+// .yaml/.yml — YAML, всё остальное — JSON.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &cfg)
+	default:
+		err = json.Unmarshal(data, &cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать файл ключей %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// tokenBucket — простой token bucket для ограничения запросов/минуту.
+type tokenBucket struct {
+	ratePerSec float64
+	capacity   float64
+
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(requestsPerMinute int) *tokenBucket {
+	capacity := float64(requestsPerMinute)
+	return &tokenBucket{
+		ratePerSec: capacity / 60,
+		capacity:   capacity,
+		tokens:     capacity,
+		lastRefill: time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.lastRefill = now
+
+	b.tokens += elapsed * b.ratePerSec
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// KeyState — состояние одного API-ключа в памяти процесса: набор областей
+// доступа, квота и текущее использование. Счётчик использованных байт не
+// переживает перезапуск сервера — квота ограничивает рост хранилища между
+// перезапусками, а не является учётом на весь срок жизни ключа.
+type KeyState struct {
+	scopes map[Scope]bool
+	quota  Quota
+	bucket *tokenBucket
+
+	mu        sync.Mutex
+	bytesUsed int64
+}
+
+func newKeyState(cfg KeyConfig) *KeyState {
+	scopes := make(map[Scope]bool, len(cfg.Scopes))
+	for _, s := range cfg.Scopes {
+		scopes[s] = true
+	}
+
+	var bucket *tokenBucket
+	if cfg.Quota.MaxRequestsPerMin > 0 {
+		bucket = newTokenBucket(cfg.Quota.MaxRequestsPerMin)
+	}
+
+	return &KeyState{scopes: scopes, quota: cfg.Quota, bucket: bucket}
+}
+
+// HasScope сообщает, включает ли ключ указанную область доступа, либо
+// область admin, которая перекрывает все остальные.
+func (s *KeyState) HasScope(scope Scope) bool {
+	return s.scopes[scope] || s.scopes[ScopeAdmin]
+}
+
+// AllowRequest расходует токен из бакета (если на ключ настроен лимит
+// запросов/минуту) и сообщает, не исчерпан ли он.
+func (s *KeyState) AllowRequest() bool {
+	if s.bucket == nil {
+		return true
+	}
+	return s.bucket.allow()
+}
+
+// TryReserveBytes проверяет, не превысит ли загрузка размером n квоту по
+// объёму хранилища, и если нет — сразу резервирует эти байты за ключом.
+func (s *KeyState) TryReserveBytes(n int64) bool {
+	if s.quota.MaxBytesStored <= 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bytesUsed+n > s.quota.MaxBytesStored {
+		return false
+	}
+	s.bytesUsed += n
+	return true
+}
+
+// ReleaseBytes откатывает резерв TryReserveBytes, когда загрузка в итоге не удалась.
+func (s *KeyState) ReleaseBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bytesUsed -= n
+	if s.bytesUsed < 0 {
+		s.bytesUsed = 0
+	}
+}
+
+// allAccess — синтетическое состояние для успешной Basic-авторизации: полный
+// доступ, без квот.
+var allAccess = &KeyState{scopes: map[Scope]bool{ScopeAdmin: true}}
+
+// Authenticator проверяет Bearer- и Basic-авторизацию запросов согласно
+// загруженному Config.
+type Authenticator struct {
+	keys           map[string]*KeyState
+	basicUser      string
+	basicPass      string
+	publicDownload bool
+}
+
+// New строит Authenticator из Config. Пустой basicUser отключает Basic-auth.
+func New(cfg *Config) *Authenticator {
+	a := &Authenticator{
+		keys:           make(map[string]*KeyState, len(cfg.Keys)),
+		basicUser:      cfg.BasicAuthUser,
+		basicPass:      cfg.BasicAuthPass,
+		publicDownload: cfg.PublicDownload,
+	}
+	for _, k := range cfg.Keys {
+		a.keys[k.Key] = newKeyState(k)
+	}
+	return a
+}
+
+// PublicDownload сообщает, должен ли GET /files/:filename оставаться
+// доступным без авторизации (настраивается полем public_download).
+func (a *Authenticator) PublicDownload() bool {
+	return a.publicDownload
+}
+
+const contextKeyState = "auth.keyState"
+
+// StateFromContext возвращает KeyState, сохранённый посреди запроса
+// middleware'ом RequireScope/OptionalAuth.
+func StateFromContext(c *gin.Context) (*KeyState, bool) {
+	v, ok := c.Get(contextKeyState)
+	if !ok {
+		return nil, false
+	}
+	state, ok := v.(*KeyState)
+	return state, ok
+}
+
+// authenticate разбирает заголовок Authorization (Bearer или Basic) и
+// возвращает соответствующее KeyState.
+func (a *Authenticator) authenticate(c *gin.Context) (*KeyState, error) {
+	header := c.GetHeader("Authorization")
+	switch {
+	case strings.HasPrefix(header, "Bearer "):
+		token := strings.TrimPrefix(header, "Bearer ")
+		state, ok := a.keys[token]
+		if !ok {
+			return nil, fmt.Errorf("недействительный API-ключ")
+		}
+		return state, nil
+	case strings.HasPrefix(header, "Basic "):
+		user, pass, ok := c.Request.BasicAuth()
+		if !ok || a.basicUser == "" || !constantTimeEqual(user, a.basicUser) || !constantTimeEqual(pass, a.basicPass) {
+			return nil, fmt.Errorf("неверные логин или пароль")
+		}
+		return allAccess, nil
+	default:
+		return nil, fmt.Errorf("требуется авторизация: заголовок Authorization отсутствует")
+	}
+}
+
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// RequireScope возвращает middleware, которое требует валидную авторизацию
+// с указанной областью доступа и учитывает лимит запросов/минуту ключа.
+func (a *Authenticator) RequireScope(scope Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, err := a.authenticate(c)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Basic realm="ginFileServer"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if !state.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("недостаточно прав: требуется область %q", scope)})
+			return
+		}
+		if !state.AllowRequest() {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "превышен лимит запросов для этого ключа"})
+			return
+		}
+
+		c.Set(contextKeyState, state)
+		c.Next()
+	}
+}
+
+// OptionalAuth — как RequireScope, но при отсутствии или невалидности
+// Authorization просто пропускает запрос дальше без KeyState в контексте.
+// Используется для маршрутов, которые можно сделать публичными.
+func (a *Authenticator) OptionalAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, err := a.authenticate(c)
+		if err == nil {
+			c.Set(contextKeyState, state)
+		}
+		c.Next()
+	}
+}
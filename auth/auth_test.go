@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestKeyState_HasScope(t *testing.T) {
+	s := newKeyState(KeyConfig{Scopes: []Scope{ScopeUpload}})
+	if !s.HasScope(ScopeUpload) {
+		t.Fatal("ожидалось, что ключ имеет область upload")
+	}
+	if s.HasScope(ScopeDelete) {
+		t.Fatal("ключ не должен иметь область delete")
+	}
+}
+
+func TestKeyState_AdminScopeOverridesAll(t *testing.T) {
+	s := newKeyState(KeyConfig{Scopes: []Scope{ScopeAdmin}})
+	for _, scope := range []Scope{ScopeUpload, ScopeDownload, ScopeList, ScopeDelete} {
+		if !s.HasScope(scope) {
+			t.Fatalf("область admin должна перекрывать %q", scope)
+		}
+	}
+}
+
+func newTestAuthenticator(key string, scopes []Scope, quota Quota) *Authenticator {
+	return New(&Config{Keys: []KeyConfig{{Key: key, Scopes: scopes, Quota: quota}}})
+}
+
+func serveWithScope(a *Authenticator, scope Scope, req *http.Request) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.GET("/x", a.RequireScope(scope), func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireScope_RejectsMissingAuthorization(t *testing.T) {
+	a := newTestAuthenticator("abc", []Scope{ScopeUpload}, Quota{})
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+
+	w := serveWithScope(a, ScopeUpload, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireScope_RejectsInsufficientScope(t *testing.T) {
+	a := newTestAuthenticator("abc", []Scope{ScopeDownload}, Quota{})
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+
+	w := serveWithScope(a, ScopeUpload, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequireScope_AllowsMatchingScope(t *testing.T) {
+	a := newTestAuthenticator("abc", []Scope{ScopeUpload}, Quota{})
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Authorization", "Bearer abc")
+
+	w := serveWithScope(a, ScopeUpload, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestRequireScope_RejectsUnknownKey(t *testing.T) {
+	a := newTestAuthenticator("abc", []Scope{ScopeUpload}, Quota{})
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.Header.Set("Authorization", "Bearer другой-ключ")
+
+	w := serveWithScope(a, ScopeUpload, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestTryReserveBytes_QuotaExhaustion(t *testing.T) {
+	s := newKeyState(KeyConfig{Quota: Quota{MaxBytesStored: 100}})
+
+	if !s.TryReserveBytes(60) {
+		t.Fatal("ожидался успешный резерв в пределах квоты")
+	}
+	if s.TryReserveBytes(60) {
+		t.Fatal("ожидался отказ: квота исчерпана")
+	}
+
+	s.ReleaseBytes(60)
+	if !s.TryReserveBytes(60) {
+		t.Fatal("ожидался успешный резерв после освобождения байт")
+	}
+}
+
+func TestTryReserveBytes_NoQuotaMeansUnlimited(t *testing.T) {
+	s := newKeyState(KeyConfig{})
+	if !s.TryReserveBytes(1 << 40) {
+		t.Fatal("без заданной квоты резерв любого объёма должен проходить")
+	}
+}
+
+func TestTokenBucket_RateLimitAndReset(t *testing.T) {
+	b := newTokenBucket(60) // 60 запросов/мин => 1 токен/сек
+
+	for i := 0; i < 60; i++ {
+		if !b.allow() {
+			t.Fatalf("запрос %d неожиданно отклонён до исчерпания бакета", i)
+		}
+	}
+	if b.allow() {
+		t.Fatal("ожидался отказ: бакет исчерпан")
+	}
+
+	// Симулируем течение времени, сдвигая lastRefill в прошлое, вместо
+	// реального time.Sleep — бакет должен восстановить токены.
+	b.mu.Lock()
+	b.lastRefill = b.lastRefill.Add(-2 * time.Second)
+	b.mu.Unlock()
+
+	if !b.allow() {
+		t.Fatal("ожидался успех: бакет должен был восстановиться спустя 2 секунды")
+	}
+}
+
+func TestRequireScope_EnforcesRateLimitAndReturns429(t *testing.T) {
+	a := newTestAuthenticator("abc", []Scope{ScopeUpload}, Quota{MaxRequestsPerMin: 1})
+
+	do := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/x", nil)
+		req.Header.Set("Authorization", "Bearer abc")
+		return serveWithScope(a, ScopeUpload, req).Code
+	}
+
+	if code := do(); code != http.StatusOK {
+		t.Fatalf("первый запрос: code = %d, want %d", code, http.StatusOK)
+	}
+	if code := do(); code != http.StatusTooManyRequests {
+		t.Fatalf("второй запрос: code = %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestAuthenticate_BasicAuthGrantsAllAccess(t *testing.T) {
+	a := New(&Config{BasicAuthUser: "admin", BasicAuthPass: "secret"})
+	r := gin.New()
+	r.GET("/x", a.RequireScope(ScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.SetBasicAuth("admin", "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestAuthenticate_BasicAuthRejectsWrongPassword(t *testing.T) {
+	a := New(&Config{BasicAuthUser: "admin", BasicAuthPass: "secret"})
+	r := gin.New()
+	r.GET("/x", a.RequireScope(ScopeAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/x", nil)
+	req.SetBasicAuth("admin", "неверный")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("code = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
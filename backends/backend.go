@@ -0,0 +1,109 @@
+// Package backends описывает интерфейс хранилища файлов, за которым может
+// стоять как локальная файловая система, так и S3-совместимое хранилище.
+package backends
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metadata — сведения о хранимом файле, не зависящие от конкретного backend'а.
+type Metadata struct {
+	Size      int64
+	ModTime   time.Time
+	MimeType  string
+	SHA256    string
+	Expiry    *time.Time // nil, если срок хранения не ограничен
+	DeleteKey string
+}
+
+// FileInfo — краткая информация о файле для листинга каталога.
+type FileInfo struct {
+	Key      string
+	Size     int64
+	ModTime  time.Time
+	MimeType string     // пусто, если backend не знает тип без полного Get (например S3)
+	SHA256   string     // пусто, если backend не знает хэш без полного Get (например S3)
+	Expiry   *time.Time // nil, если backend не знает срок хранения без полного Get (например S3)
+}
+
+// PutOptions — параметры, сопровождающие содержимое файла при сохранении.
+// Backend обязан сохранить их как метаданные и вернуть обратно из Get (и, по
+// возможности, из List).
+type PutOptions struct {
+	MimeType  string // MIME-тип, определённый по содержимому (см. sniffContentType в main.go)
+	DeleteKey string // ключ, который потребуется для удаления файла
+	Expiry    *time.Time
+}
+
+// Backend — абстракция над хранилищем файлов. Все обработчики в main.go
+// должны работать через этот интерфейс, а не напрямую с os.* вызовами.
+type Backend interface {
+	Put(key string, r io.Reader, size int64, opts PutOptions) error
+	Get(key string) (io.ReadCloser, Metadata, error)
+	Delete(key string) error
+	List() ([]FileInfo, error)
+	Exists(key string) (bool, error)
+}
+
+// RangeResult описывает, что backend в итоге отдал в ответ на GetRange.
+// Partial=false означает, что backend проигнорировал Range (например, он был
+// некорректным или неудовлетворимым) и вернул файл целиком — вызывающий код
+// должен ответить 200, а не 206. Start/End/Total включительны и валидны
+// только при Partial=true; Total — полный размер объекта в обоих случаях.
+type RangeResult struct {
+	Partial    bool
+	Start, End int64
+	Total      int64
+}
+
+// RangeGetter — необязательный интерфейс для backend'ов, чей Get не отдаёт
+// io.ReadSeeker (поэтому http.ServeContent для них не работает), но которые
+// всё равно умеют отдать произвольный байтовый диапазон без буферизации
+// всего файла. rangeHeader передаётся как есть, в исходном виде клиентского
+// заголовка Range (например S3 понимает тот же синтаксис "bytes=...", что и
+// HTTP) — это позволяет получить нужный диапазон одним обращением к backend'у,
+// не запрашивая сперва размер файла отдельным Get.
+type RangeGetter interface {
+	GetRange(key, rangeHeader string) (io.ReadCloser, RangeResult, error)
+}
+
+// ParseContentRange разбирает значение вида "bytes start-end/total" — в этом
+// формате клиент присылает Content-Range при закачке чанка (см.
+// handleUploadChunk в main.go), и в этом же формате backend'ы, отдающие
+// частичный ответ на GetRange (например S3), описывают фактически
+// возвращённый диапазон в своём ответе.
+func ParseContentRange(header string) (start, end, total int64, err error) {
+	header = strings.TrimPrefix(header, "bytes ")
+	parts := strings.SplitN(header, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, 0, fmt.Errorf("некорректный формат Content-Range")
+	}
+	total, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("некорректный total в Content-Range")
+	}
+	rangeParts := strings.SplitN(parts[0], "-", 2)
+	if len(rangeParts) != 2 {
+		return 0, 0, 0, fmt.Errorf("некорректный диапазон в Content-Range")
+	}
+	start, err = strconv.ParseInt(rangeParts[0], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("некорректное начало диапазона")
+	}
+	end, err = strconv.ParseInt(rangeParts[1], 10, 64)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("некорректный конец диапазона")
+	}
+	return start, end, total, nil
+}
+
+// ErrNotExist возвращается реализациями, когда ключ не найден в хранилище.
+var ErrNotExist = &notExistError{}
+
+type notExistError struct{}
+
+func (e *notExistError) Error() string { return "backends: ключ не найден" }
@@ -0,0 +1,186 @@
+// Package localfs реализует backends.Backend поверх обычной файловой системы,
+// сохраняя поведение исходного сервера и добавляя JSON-сайдкар с метаданными
+// рядом с каждым файлом.
+package localfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/moiseevv/ginFileServer/backends"
+)
+
+// LocalFS хранит файлы в одной директории на диске.
+type LocalFS struct {
+	dir string
+}
+
+// New создаёт LocalFS backend, предварительно создавая директорию, если её нет.
+func New(dir string) (*LocalFS, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	return &LocalFS{dir: dir}, nil
+}
+
+type sidecar struct {
+	Size      int64      `json:"size"`
+	MimeType  string     `json:"mimetype"`
+	SHA256    string     `json:"sha256"`
+	Expiry    *time.Time `json:"expiry,omitempty"`
+	DeleteKey string     `json:"delete_key,omitempty"`
+}
+
+func (l *LocalFS) path(key string) string {
+	return filepath.Join(l.dir, key)
+}
+
+func (l *LocalFS) sidecarPath(key string) string {
+	return filepath.Join(l.dir, key+".meta.json")
+}
+
+func (l *LocalFS) Put(key string, r io.Reader, size int64, opts backends.PutOptions) error {
+	f, err := os.Create(l.path(key))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(f, io.TeeReader(r, hasher)); err != nil {
+		return err
+	}
+
+	mimeType := opts.MimeType
+	if mimeType == "" {
+		mimeType = guessMimeType(key)
+	}
+	meta := sidecar{
+		Size:      size,
+		MimeType:  mimeType,
+		SHA256:    hex.EncodeToString(hasher.Sum(nil)),
+		Expiry:    opts.Expiry,
+		DeleteKey: opts.DeleteKey,
+	}
+	return l.writeSidecar(key, meta)
+}
+
+func (l *LocalFS) Get(key string) (io.ReadCloser, backends.Metadata, error) {
+	f, err := os.Open(l.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, backends.Metadata{}, backends.ErrNotExist
+		}
+		return nil, backends.Metadata{}, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, backends.Metadata{}, err
+	}
+
+	meta := backends.Metadata{
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		MimeType: guessMimeType(key),
+	}
+	if sc, err := l.readSidecar(key); err == nil {
+		meta.MimeType = sc.MimeType
+		meta.SHA256 = sc.SHA256
+		meta.Expiry = sc.Expiry
+		meta.DeleteKey = sc.DeleteKey
+	}
+
+	return f, meta, nil
+}
+
+func (l *LocalFS) Delete(key string) error {
+	if err := os.Remove(l.path(key)); err != nil {
+		if os.IsNotExist(err) {
+			return backends.ErrNotExist
+		}
+		return err
+	}
+	os.Remove(l.sidecarPath(key))
+	return nil
+}
+
+func (l *LocalFS) List() ([]backends.FileInfo, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []backends.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() || isSidecarOrArtifact(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		file := backends.FileInfo{
+			Key:     entry.Name(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		}
+		if sc, err := l.readSidecar(entry.Name()); err == nil {
+			file.MimeType = sc.MimeType
+			file.SHA256 = sc.SHA256
+			file.Expiry = sc.Expiry
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (l *LocalFS) Exists(key string) (bool, error) {
+	_, err := os.Stat(l.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *LocalFS) writeSidecar(key string, meta sidecar) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.sidecarPath(key), data, 0o644)
+}
+
+func (l *LocalFS) readSidecar(key string) (sidecar, error) {
+	var meta sidecar
+	data, err := os.ReadFile(l.sidecarPath(key))
+	if err != nil {
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+func isSidecarOrArtifact(name string) bool {
+	return strings.HasSuffix(name, ".meta.json") ||
+		strings.HasSuffix(name, ".part") ||
+		strings.HasSuffix(name, ".part.json")
+}
+
+func guessMimeType(key string) string {
+	if t := mime.TypeByExtension(filepath.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
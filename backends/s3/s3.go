@@ -0,0 +1,236 @@
+// Package s3 реализует backends.Backend поверх S3-совместимого хранилища
+// (AWS S3, MinIO и т.п.) с использованием aws-sdk-go-v2.
+package s3
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"github.com/moiseevv/ginFileServer/backends"
+)
+
+// Config описывает параметры подключения к S3-совместимому хранилищу.
+type Config struct {
+	Endpoint     string // пусто — использовать AWS по умолчанию; иначе адрес MinIO и т.п.
+	Region       string
+	Bucket       string
+	AccessKey    string
+	SecretKey    string
+	UsePathStyle bool // нужно для большинства развёртываний MinIO
+}
+
+// S3 — backend на базе S3-совместимого API.
+type S3 struct {
+	client *s3.Client
+	bucket string
+}
+
+// New создаёт S3 backend по переданной конфигурации.
+func New(ctx context.Context, cfg Config) (*S3, error) {
+	loadOpts := []func(*awsconfig.LoadOptions) error{
+		awsconfig.WithRegion(cfg.Region),
+	}
+	if cfg.AccessKey != "" {
+		loadOpts = append(loadOpts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKey, cfg.SecretKey, ""),
+		))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.UsePathStyle
+	})
+
+	return &S3{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (s *S3) Put(key string, r io.Reader, size int64, opts backends.PutOptions) error {
+	ctx := context.Background()
+
+	metadata := map[string]string{}
+	if opts.DeleteKey != "" {
+		metadata["delete-key"] = opts.DeleteKey
+	}
+	if opts.Expiry != nil {
+		metadata["expiry"] = opts.Expiry.Format(time.RFC3339)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		ContentLength: aws.Int64(size),
+	}
+	if opts.MimeType != "" {
+		input.ContentType = aws.String(opts.MimeType)
+	}
+	if len(metadata) > 0 {
+		input.Metadata = metadata
+	}
+
+	// sha256 можно узнать только прочитав всё тело, а PutObject и так читает
+	// его целиком для загрузки — считаем хэш попутно через TeeReader и
+	// дописываем его отдельным CopyObject, заменяющим метаданные объекта.
+	hasher := sha256.New()
+	input.Body = io.TeeReader(r, hasher)
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return err
+	}
+
+	metadata["sha256"] = hex.EncodeToString(hasher.Sum(nil))
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:            aws.String(s.bucket),
+		Key:               aws.String(key),
+		CopySource:        aws.String(s.bucket + "/" + key),
+		ContentType:       input.ContentType,
+		Metadata:          metadata,
+		MetadataDirective: types.MetadataDirectiveReplace,
+	})
+	return err
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, backends.Metadata, error) {
+	ctx := context.Background()
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, backends.Metadata{}, backends.ErrNotExist
+		}
+		return nil, backends.Metadata{}, err
+	}
+
+	meta := backends.Metadata{
+		Size: aws.ToInt64(out.ContentLength),
+	}
+	if out.LastModified != nil {
+		meta.ModTime = *out.LastModified
+	}
+	if out.ContentType != nil {
+		meta.MimeType = *out.ContentType
+	}
+	if v, ok := out.Metadata["sha256"]; ok {
+		meta.SHA256 = v
+	}
+	if v, ok := out.Metadata["delete-key"]; ok {
+		meta.DeleteKey = v
+	}
+	if v, ok := out.Metadata["expiry"]; ok {
+		if expiry, err := time.Parse(time.RFC3339, v); err == nil {
+			meta.Expiry = &expiry
+		}
+	}
+
+	return out.Body, meta, nil
+}
+
+// GetRange реализует backends.RangeGetter: GetObject с телом S3 не является
+// io.ReadSeeker, поэтому http.ServeContent в main.go не может сам обслужить
+// Range-запрос к этому backend'у. rangeHeader пересылается в S3 как есть (тот
+// же синтаксис "bytes=..."), поэтому весь диапазон получается одним
+// GetObject — без отдельного запроса за размером файла. Фактически
+// возвращённый диапазон разбирается из ответного заголовка Content-Range; его
+// отсутствие означает, что S3 посчитал Range неприменимым и отдал объект
+// целиком (RangeResult.Partial=false).
+func (s *S3) GetRange(key, rangeHeader string) (io.ReadCloser, backends.RangeResult, error) {
+	ctx := context.Background()
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Range:  aws.String(rangeHeader),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, backends.RangeResult{}, backends.ErrNotExist
+		}
+		return nil, backends.RangeResult{}, err
+	}
+
+	contentRange := aws.ToString(out.ContentRange)
+	if contentRange == "" {
+		return out.Body, backends.RangeResult{Total: aws.ToInt64(out.ContentLength)}, nil
+	}
+
+	start, end, total, perr := backends.ParseContentRange(contentRange)
+	if perr != nil {
+		out.Body.Close()
+		return nil, backends.RangeResult{}, fmt.Errorf("не удалось разобрать Content-Range от S3: %w", perr)
+	}
+	return out.Body, backends.RangeResult{Partial: true, Start: start, End: end, Total: total}, nil
+}
+
+func (s *S3) Delete(key string) error {
+	ctx := context.Background()
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// List не возвращает MimeType, SHA256 и Expiry: ListObjectsV2 отдаёт только
+// ключ, размер и дату изменения, а подтягивать метаданные объекта по
+// отдельности на каждый файл значило бы по одному HeadObject-запросу на файл
+// в листинге.
+func (s *S3) List() ([]backends.FileInfo, error) {
+	ctx := context.Background()
+	var files []backends.FileInfo
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			info := backends.FileInfo{
+				Key:  aws.ToString(obj.Key),
+				Size: aws.ToInt64(obj.Size),
+			}
+			if obj.LastModified != nil {
+				info.ModTime = *obj.LastModified
+			}
+			files = append(files, info)
+		}
+	}
+	return files, nil
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	ctx := context.Background()
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
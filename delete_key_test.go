@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCheckDeleteKey_NoKeyRequired(t *testing.T) {
+	if status := checkDeleteKey("", ""); status != 0 {
+		t.Fatalf("ожидали 0 (без проверки), получили %d", status)
+	}
+	if status := checkDeleteKey("", "anything"); status != 0 {
+		t.Fatalf("ожидали 0 (без проверки), получили %d", status)
+	}
+}
+
+func TestCheckDeleteKey_MissingHeaderIs404(t *testing.T) {
+	if status := checkDeleteKey("secret", ""); status != http.StatusNotFound {
+		t.Fatalf("ожидали 404 при отсутствующем заголовке, получили %d", status)
+	}
+}
+
+func TestCheckDeleteKey_WrongKeyIs403(t *testing.T) {
+	if status := checkDeleteKey("secret", "wrong"); status != http.StatusForbidden {
+		t.Fatalf("ожидали 403 при неверном ключе, получили %d", status)
+	}
+}
+
+func TestCheckDeleteKey_CorrectKeyPasses(t *testing.T) {
+	if status := checkDeleteKey("secret", "secret"); status != 0 {
+		t.Fatalf("ожидали 0 при верном ключе, получили %d", status)
+	}
+}
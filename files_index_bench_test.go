@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// buildBenchIndex строит fileIndexEntry-срез нужного размера для бенчмарков
+// GET /files — имена чередуются по расширению, чтобы запросы с ?glob= не
+// вырождались в полное совпадение или полное отсутствие совпадений.
+func buildBenchIndex(n int) []fileIndexEntry {
+	exts := []string{".pdf", ".png", ".txt", ".zip"}
+	entries := make([]fileIndexEntry, n)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		entries[i] = fileIndexEntry{
+			Name:    fmt.Sprintf("file-%06d%s", i, exts[i%len(exts)]),
+			Size:    int64(i),
+			ModTime: base.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return entries
+}
+
+// BenchmarkFilesEndpoint_100k воспроизводит путь обработки GET /files
+// (фильтрация по глобу, сортировка, пагинация) на индексе из 100 тысяч
+// файлов, чтобы убедиться, что сам запрос остаётся быстрым — JSON-сериализация
+// и запись в ResponseWriter вне зоны ответственности этих функций и сюда не
+// включены.
+func BenchmarkFilesEndpoint_100k(b *testing.B) {
+	entries := buildBenchIndex(100_000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matched, err := filterFileIndex(entries, "", "*.pdf")
+		if err != nil {
+			b.Fatalf("filterFileIndex вернул ошибку: %v", err)
+		}
+		sortFileIndex(matched, "size", "desc")
+		_ = paginateFileIndex(matched, "50", "0")
+	}
+}
+
+// BenchmarkFilesEndpoint_100k_NoFilter измеряет тот же путь без фильтрации
+// (сортировка и пагинация всего индекса), наиболее затратный случай.
+func BenchmarkFilesEndpoint_100k_NoFilter(b *testing.B) {
+	entries := buildBenchIndex(100_000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matched, err := filterFileIndex(entries, "", "")
+		if err != nil {
+			b.Fatalf("filterFileIndex вернул ошибку: %v", err)
+		}
+		sortFileIndex(matched, "name", "asc")
+		_ = paginateFileIndex(matched, "50", "0")
+	}
+}
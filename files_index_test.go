@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestFilterFileIndex_GlobMatchesFilepathSemantics(t *testing.T) {
+	entries := []fileIndexEntry{
+		{Name: "report.pdf"},
+		{Name: "photo.png"},
+		{Name: "archive.tar.gz"},
+		{Name: "notes.txt"},
+		{Name: "image-001.png"},
+	}
+
+	cases := []struct {
+		glob string
+		want []string
+	}{
+		{glob: "", want: []string{"report.pdf", "photo.png", "archive.tar.gz", "notes.txt", "image-001.png"}},
+		{glob: "*.pdf", want: []string{"report.pdf"}},
+		{glob: "*.png", want: []string{"photo.png", "image-001.png"}},
+		{glob: "image-*", want: []string{"image-001.png"}},
+		{glob: "report.pdf", want: []string{"report.pdf"}},
+		{glob: "image-???.png", want: []string{"image-001.png"}},
+		{glob: "*.zip", want: nil},
+	}
+
+	for _, tc := range cases {
+		got, err := filterFileIndex(entries, "", tc.glob)
+		if err != nil {
+			t.Fatalf("glob %q: filterFileIndex вернул ошибку: %v", tc.glob, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Fatalf("glob %q: ожидали %v, получили %v", tc.glob, tc.want, got)
+		}
+		for i, e := range got {
+			if e.Name != tc.want[i] {
+				t.Fatalf("glob %q: ожидали %v, получили %v", tc.glob, tc.want, got)
+			}
+		}
+	}
+}
+
+func TestFilterFileIndex_RejectsBadGlob(t *testing.T) {
+	entries := []fileIndexEntry{{Name: "report.pdf"}}
+	if _, err := filterFileIndex(entries, "", "[unterminated"); err == nil {
+		t.Fatal("ожидали ошибку для незакрытого класса символов в glob")
+	}
+}
+
+func TestFilterFileIndex_CombinesQueryAndGlob(t *testing.T) {
+	entries := []fileIndexEntry{
+		{Name: "Invoice-2024.pdf"},
+		{Name: "invoice-2023.txt"},
+		{Name: "receipt-2024.pdf"},
+	}
+	got, err := filterFileIndex(entries, "invoice", "*.pdf")
+	if err != nil {
+		t.Fatalf("filterFileIndex вернул ошибку: %v", err)
+	}
+	if len(got) != 1 || got[0].Name != "Invoice-2024.pdf" {
+		t.Fatalf("ожидали только Invoice-2024.pdf, получили %v", got)
+	}
+}
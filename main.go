@@ -1,23 +1,401 @@
 package main
 
 import (
+	"archive/tar"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"hash"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/moiseevv/ginFileServer/auth"
+	"github.com/moiseevv/ginFileServer/backends"
+	"github.com/moiseevv/ginFileServer/backends/localfs"
+	"github.com/moiseevv/ginFileServer/backends/s3"
 )
 
+// Как долго храним метаданные незавершённой закачки, прежде чем считать её брошенной
+const chunkUploadTTL = 24 * time.Hour
+
+// Как часто запускается фоновая уборка брошенных частичных закачек
+const chunkSweepInterval = 10 * time.Minute
+
+// Как часто в фоне перестраивается индекс файлов для GET /files
+const fileIndexRebuildInterval = 15 * time.Second
+
+// config собирает параметры запуска сервера, взятые из CLI-флагов или
+// переменных окружения (флаг всегда имеет приоритет над env).
+type config struct {
+	storageBackend string
+	uploadDir      string
+
+	s3Endpoint     string
+	s3Region       string
+	s3Bucket       string
+	s3AccessKey    string
+	s3SecretKey    string
+	s3UsePathStyle bool
+
+	allowMime []string // глобы вида "image/*"; если не пусто — разрешены только перечисленные типы
+	denyMime  []string // глобы вида "application/x-msdownload"; проверяются раньше allowMime
+
+	maxExpiry           time.Duration // верхняя граница для X-File-Expiry; 0 — без ограничения
+	expirySweepInterval time.Duration // как часто искать и удалять файлы с истёкшим сроком
+
+	authConfigPath string // путь к JSON/YAML файлу с API-ключами; пусто — авторизация отключена
+}
+
+func loadConfig() config {
+	var cfg config
+	var allowMimeRaw, denyMimeRaw string
+	flag.StringVar(&cfg.storageBackend, "storage-backend", envOrDefault("STORAGE_BACKEND", "local"), "бэкенд хранилища: local или s3")
+	flag.StringVar(&cfg.uploadDir, "upload-dir", envOrDefault("UPLOAD_DIR", "./uploads"), "директория для локального хранения и стейджинга закачек")
+	flag.StringVar(&cfg.s3Endpoint, "s3-endpoint", envOrDefault("S3_ENDPOINT", ""), "адрес S3-совместимого хранилища (пусто для AWS S3)")
+	flag.StringVar(&cfg.s3Region, "s3-region", envOrDefault("S3_REGION", "us-east-1"), "регион S3")
+	flag.StringVar(&cfg.s3Bucket, "s3-bucket", envOrDefault("S3_BUCKET", ""), "бакет S3")
+	flag.StringVar(&cfg.s3AccessKey, "s3-access-key", envOrDefault("S3_ACCESS_KEY", ""), "ключ доступа S3")
+	flag.StringVar(&cfg.s3SecretKey, "s3-secret-key", envOrDefault("S3_SECRET_KEY", ""), "секретный ключ S3")
+	flag.BoolVar(&cfg.s3UsePathStyle, "s3-path-style", envOrDefault("S3_PATH_STYLE", "") == "true", "использовать path-style адресацию (нужно для MinIO)")
+	flag.StringVar(&allowMimeRaw, "allow-mime", envOrDefault("ALLOW_MIME", ""), "разрешённые MIME-типы через запятую (глобы вида image/*); пусто — разрешено всё, кроме deny-mime")
+	flag.StringVar(&denyMimeRaw, "deny-mime", envOrDefault("DENY_MIME", ""), "запрещённые MIME-типы через запятую (глобы вида application/x-msdownload)")
+	flag.DurationVar(&cfg.maxExpiry, "max-expiry", 0, "максимальный срок хранения файла, который можно запросить через X-File-Expiry (0 — без ограничения)")
+	flag.DurationVar(&cfg.expirySweepInterval, "expiry-sweep-interval", 10*time.Minute, "как часто запускать фоновое удаление файлов с истёкшим сроком хранения")
+	flag.StringVar(&cfg.authConfigPath, "auth-config", envOrDefault("AUTH_CONFIG", ""), "путь к JSON/YAML файлу с API-ключами и их областями доступа (пусто — авторизация отключена)")
+	flag.Parse()
+	cfg.allowMime = parseMimeList(allowMimeRaw)
+	cfg.denyMime = parseMimeList(denyMimeRaw)
+	return cfg
+}
+
+func envOrDefault(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return def
+}
+
+// newBackend выбирает реализацию backends.Backend согласно конфигурации.
+func newBackend(cfg config) (backends.Backend, error) {
+	switch cfg.storageBackend {
+	case "s3":
+		return s3.New(context.Background(), s3.Config{
+			Endpoint:     cfg.s3Endpoint,
+			Region:       cfg.s3Region,
+			Bucket:       cfg.s3Bucket,
+			AccessKey:    cfg.s3AccessKey,
+			SecretKey:    cfg.s3SecretKey,
+			UsePathStyle: cfg.s3UsePathStyle,
+		})
+	case "local", "":
+		return localfs.New(cfg.uploadDir)
+	default:
+		return nil, fmt.Errorf("неизвестный storage-backend: %s", cfg.storageBackend)
+	}
+}
+
+// requireScope оборачивает auth.Authenticator.RequireScope; если авторизация
+// отключена (authn == nil), возвращает no-op middleware, чтобы сервер
+// продолжал работать без файла ключей, как и раньше.
+func requireScope(authn *auth.Authenticator, scope auth.Scope) gin.HandlerFunc {
+	if authn == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return authn.RequireScope(scope)
+}
+
+// fileIndexEntry — одна запись в индексе файлов, собранная из backends.FileInfo.
+type fileIndexEntry struct {
+	Name     string
+	Size     int64
+	ModTime  time.Time
+	MimeType string
+	SHA256   string
+	Expiry   *time.Time
+}
+
+// fileIndex — индекс всех файлов backend'а, который периодически
+// перестраивается в фоне (см. watchFileIndex), подобно makeIndex в
+// gohttpserver, чтобы GET /files мог фильтровать, сортировать и листать
+// результаты, не обращаясь к backend'у на каждый запрос.
+type fileIndex struct {
+	mu         sync.RWMutex
+	entries    []fileIndexEntry
+	generation int64
+	builtAt    time.Time
+}
+
+// rebuild запрашивает у backend'а актуальный список файлов и атомарно
+// подменяет содержимое индекса.
+func (idx *fileIndex) rebuild(backend backends.Backend) error {
+	files, err := backend.List()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]fileIndexEntry, len(files))
+	for i, f := range files {
+		entries[i] = fileIndexEntry{
+			Name:     f.Key,
+			Size:     f.Size,
+			ModTime:  f.ModTime,
+			MimeType: f.MimeType,
+			SHA256:   f.SHA256,
+			Expiry:   f.Expiry,
+		}
+	}
+
+	idx.mu.Lock()
+	idx.entries = entries
+	idx.generation++
+	idx.builtAt = time.Now()
+	idx.mu.Unlock()
+	return nil
+}
+
+// snapshot возвращает копию текущих записей индекса вместе с поколением и
+// временем последней перестройки (нужны для ETag/Last-Modified).
+func (idx *fileIndex) snapshot() ([]fileIndexEntry, int64, time.Time) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	entries := make([]fileIndexEntry, len(idx.entries))
+	copy(entries, idx.entries)
+	return entries, idx.generation, idx.builtAt
+}
+
+// watchFileIndex периодически перестраивает индекс в фоне.
+func watchFileIndex(idx *fileIndex, backend backends.Backend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		idx.rebuild(backend)
+	}
+}
+
+// filterFileIndex оставляет записи, имя которых содержит подстроку q
+// (без учёта регистра) и соответствует glob-шаблону (если он задан).
+//
+// glob компилируется один раз для всего вызова (см. compileGlob), а не на
+// каждой записи — на индексе в 100к+ файлов повторная разборка шаблона
+// через filepath.Match на каждой итерации была главным источником задержки.
+func filterFileIndex(entries []fileIndexEntry, q, glob string) ([]fileIndexEntry, error) {
+	if q == "" && glob == "" {
+		return entries, nil
+	}
+
+	var matcher *globMatcher
+	if glob != "" {
+		m, err := compileGlob(glob)
+		if err != nil {
+			return nil, err
+		}
+		matcher = m
+	}
+
+	q = strings.ToLower(q)
+	matched := make([]fileIndexEntry, 0, len(entries))
+	for _, e := range entries {
+		if q != "" && !strings.Contains(strings.ToLower(e.Name), q) {
+			continue
+		}
+		if matcher != nil && !matcher.match(e.Name) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return matched, nil
+}
+
+// globMatcher — glob-шаблон (*, ?, классы символов [...] — семантика как у
+// filepath.Match, с двумя отличиями: отрицание класса задаётся и через "!",
+// а "\" вне класса не экранирует следующий символ, а сравнивается буквально),
+// скомпилированный один раз и переиспользуемый для
+// проверки множества имён подряд. Самые частые шаблоны ("*.ext", "prefix*"
+// и шаблоны без спецсимволов вовсе) сводятся к strings.HasSuffix/HasPrefix/
+// сравнению — на индексе в 100к+ записей это на порядок быстрее, чем прогонять
+// каждое имя через regexp; произвольные шаблоны (несколько "*", "?", классы
+// символов) по-прежнему компилируются в regexp.
+type globMatcher struct {
+	re     *regexp.Regexp
+	suffix string // если re == nil и prefix == "": сравнение через strings.HasSuffix
+	prefix string // если re == nil и suffix == "": сравнение через strings.HasPrefix
+	exact  string // если re == nil, prefix == "" и suffix == "": точное совпадение
+}
+
+func (m *globMatcher) match(name string) bool {
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(name)
+	case m.suffix != "":
+		return strings.HasSuffix(name, m.suffix)
+	case m.prefix != "":
+		return strings.HasPrefix(name, m.prefix)
+	default:
+		return name == m.exact
+	}
+}
+
+// hasGlobMeta сообщает, содержит ли шаблон спецсимволы glob (*, ?, [).
+func hasGlobMeta(pattern string) bool {
+	return strings.ContainsAny(pattern, "*?[")
+}
+
+// compileGlob транслирует glob-шаблон в globMatcher. Имена файлов в индексе
+// не содержат "/", поэтому, в отличие от filepath.Match, разделитель пути
+// особым образом не обрабатывается.
+func compileGlob(pattern string) (*globMatcher, error) {
+	if !hasGlobMeta(pattern) {
+		return &globMatcher{exact: pattern}, nil
+	}
+	if strings.Count(pattern, "*") == 1 && !strings.ContainsAny(pattern, "?[") {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok && suffix != "" {
+			return &globMatcher{suffix: suffix}, nil
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && prefix != "" {
+			return &globMatcher{prefix: prefix}, nil
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '[':
+			j := i + 1
+			if j < len(pattern) && (pattern[j] == '^' || pattern[j] == '!') {
+				j++
+			}
+			if j < len(pattern) && pattern[j] == ']' {
+				j++
+			}
+			for j < len(pattern) && pattern[j] != ']' {
+				j++
+			}
+			if j >= len(pattern) {
+				return nil, fmt.Errorf("некорректный glob: незакрытый класс символов")
+			}
+			class := pattern[i : j+1]
+			if class[1] == '!' {
+				class = "[^" + class[2:]
+			}
+			b.WriteString(class)
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("некорректный glob: %w", err)
+	}
+	return &globMatcher{re: re}, nil
+}
+
+// sortFileIndex сортирует entries на месте по sortBy (name|size|modTime) в
+// порядке order (asc|desc). Неизвестный sortBy трактуется как "name".
+func sortFileIndex(entries []fileIndexEntry, sortBy, order string) {
+	desc := order == "desc"
+
+	less := func(i, j int) bool {
+		switch sortBy {
+		case "size":
+			return entries[i].Size < entries[j].Size
+		case "modTime":
+			return entries[i].ModTime.Before(entries[j].ModTime)
+		default:
+			return entries[i].Name < entries[j].Name
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if desc {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+// paginateFileIndex разбирает limit/offset (пустая строка — значение по
+// умолчанию) и возвращает соответствующий срез entries.
+func paginateFileIndex(entries []fileIndexEntry, limitParam, offsetParam string) []fileIndexEntry {
+	offset, _ := strconv.Atoi(offsetParam)
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(entries) {
+		return nil
+	}
+	entries = entries[offset:]
+
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit <= 0 {
+		return entries
+	}
+	if limit > len(entries) {
+		limit = len(entries)
+	}
+	return entries[:limit]
+}
+
 func main() {
-	// Создаём директорию для загрузок, если её нет
-	uploadDir := "./uploads"
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+	cfg := loadConfig()
+
+	// Директория для стейджинга: приём multipart-форм и сборка чанков всегда
+	// происходит на локальном диске, даже если конечное хранилище — S3.
+	if err := os.MkdirAll(cfg.uploadDir, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+	chunkStagingDir := filepath.Join(cfg.uploadDir, ".chunks")
+	if err := os.MkdirAll(chunkStagingDir, os.ModePerm); err != nil {
+		log.Fatal(err)
+	}
+
+	backend, err := newBackend(cfg)
+	if err != nil {
 		log.Fatal(err)
 	}
 
+	var authn *auth.Authenticator
+	if cfg.authConfigPath != "" {
+		authCfg, err := auth.LoadConfig(cfg.authConfigPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authn = auth.New(authCfg)
+	}
+
+	go sweepStaleChunkUploads(chunkStagingDir)
+	go sweepExpiredFiles(backend, cfg.expirySweepInterval)
+
+	filesIndex := &fileIndex{}
+	if err := filesIndex.rebuild(backend); err != nil {
+		log.Printf("не удалось построить начальный индекс файлов: %v", err)
+	}
+	go watchFileIndex(filesIndex, backend, fileIndexRebuildInterval)
+
 	r := gin.Default()
 	r.LoadHTMLGlob("templates/*")
 	r.Static("/asserts", "./asserts")
@@ -25,8 +403,8 @@ func main() {
 	// Middleware для CORS (если фронт на другом домене)
 	r.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, HEAD, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Range, Upload-Id, X-File-Expiry, X-Delete-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.JSON(200, gin.H{"message": "Проверка на опции"})
@@ -42,7 +420,7 @@ func main() {
 	})
 
 	// 📤 Загрузка одного файла
-	r.POST("/upload", func(c *gin.Context) {
+	r.POST("/upload", requireScope(authn, auth.ScopeUpload), func(c *gin.Context) {
 		// Ограничение размера файла (10 MB)
 		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 10<<20)
 
@@ -64,24 +442,120 @@ func main() {
 
 		// Генерация уникального имени файла
 		filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-		filepath_to_file := filepath.Join(uploadDir, filename)
 
-		// Сохранение файла
-		if err := c.SaveUploadedFile(file, filepath_to_file); err != nil {
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось открыть загруженный файл"})
+			return
+		}
+		defer src.Close()
+
+		// 📦 Распаковка архива вместо сохранения его целиком
+		if c.Query("extract") == "true" {
+			kind := detectArchiveKind(file.Filename)
+			if kind == archiveNone {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "extract=true поддерживается только для .zip, .tar, .tar.gz и .tar.bz2"})
+				return
+			}
+
+			expiry, err := parseFileExpiry(c.GetHeader("X-File-Expiry"), cfg.maxExpiry)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			deleteKey, err := generateDeleteKey()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сгенерировать ключ удаления"})
+				return
+			}
+
+			extractOpts := extractOptions{allowMime: cfg.allowMime, denyMime: cfg.denyMime, deleteKey: deleteKey, expiry: expiry}
+			if state, ok := auth.StateFromContext(c); ok {
+				extractOpts.reserve = state.TryReserveBytes
+				extractOpts.release = state.ReleaseBytes
+			}
+
+			var members []extractedMember
+			if kind == archiveZip {
+				members, err = extractZip(src, file.Size, backend, filename, extractOpts)
+			} else {
+				var tr *tar.Reader
+				tr, err = archiveTarReader(kind, src)
+				if err == nil {
+					members, err = extractTar(tr, backend, filename, extractOpts)
+				}
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			response := gin.H{
+				"message":    "Архив распакован",
+				"members":    members,
+				"delete_key": deleteKey,
+			}
+			if expiry != nil {
+				response["expires_at"] = expiry.Format(time.RFC3339)
+			}
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		detected, body, err := sniffContentType(src)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось определить тип содержимого"})
+			return
+		}
+		if reason := validateMime(file.Filename, detected, cfg.allowMime, cfg.denyMime); reason != "" {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+			return
+		}
+
+		expiry, err := parseFileExpiry(c.GetHeader("X-File-Expiry"), cfg.maxExpiry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		deleteKey, err := generateDeleteKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сгенерировать ключ удаления"})
+			return
+		}
+
+		if state, ok := auth.StateFromContext(c); ok {
+			if !state.TryReserveBytes(file.Size) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "превышена квота на объём хранимых файлов для этого ключа"})
+				return
+			}
+			defer func() {
+				if c.Writer.Status() != http.StatusOK {
+					state.ReleaseBytes(file.Size)
+				}
+			}()
+		}
+
+		opts := backends.PutOptions{MimeType: detected, DeleteKey: deleteKey, Expiry: expiry}
+		if err := backend.Put(filename, body, file.Size, opts); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить файл"})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"message":  "Файл успешно загружен",
-			"filename": filename,
-			"size":     file.Size,
-			"path":     filepath_to_file,
-		})
+		response := gin.H{
+			"message":    "Файл успешно загружен",
+			"filename":   filename,
+			"size":       file.Size,
+			"delete_key": deleteKey,
+		}
+		if expiry != nil {
+			response["expires_at"] = expiry.Format(time.RFC3339)
+		}
+		c.JSON(http.StatusOK, response)
 	})
 
 	// 📤 Загрузка нескольких файлов
-	r.POST("/upload/multiple", func(c *gin.Context) {
+	r.POST("/upload/multiple", requireScope(authn, auth.ScopeUpload), func(c *gin.Context) {
 		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 50<<20) // 50 MB для нескольких файлов
 
 		form, err := c.MultipartForm()
@@ -96,6 +570,19 @@ func main() {
 			return
 		}
 
+		var reserve func(int64) bool
+		var release func(int64)
+		if state, ok := auth.StateFromContext(c); ok {
+			reserve = state.TryReserveBytes
+			release = state.ReleaseBytes
+		}
+
+		expiry, err := parseFileExpiry(c.GetHeader("X-File-Expiry"), cfg.maxExpiry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
 		var uploadedFiles []gin.H
 		for _, file := range files {
 			// Проверка размера каждого файла
@@ -103,19 +590,63 @@ func main() {
 				continue // Пропускаем слишком большие файлы
 			}
 
+			if reserve != nil && !reserve(file.Size) {
+				continue // Квота ключа исчерпана
+			}
+
 			filename := fmt.Sprintf("%d_%s", time.Now().UnixNano(), file.Filename)
-			filepathToFile := filepath.Join(uploadDir, filename)
 
-			if err := c.SaveUploadedFile(file, filepathToFile); err != nil {
+			src, err := file.Open()
+			if err != nil {
+				if release != nil {
+					release(file.Size)
+				}
+				continue
+			}
+			detected, body, err := sniffContentType(src)
+			if err != nil {
+				src.Close()
+				if release != nil {
+					release(file.Size)
+				}
+				continue
+			}
+			if reason := validateMime(file.Filename, detected, cfg.allowMime, cfg.denyMime); reason != "" {
+				src.Close()
+				if release != nil {
+					release(file.Size)
+				}
+				continue
+			}
+
+			deleteKey, err := generateDeleteKey()
+			if err != nil {
+				src.Close()
+				if release != nil {
+					release(file.Size)
+				}
 				continue
 			}
 
-			uploadedFiles = append(uploadedFiles, gin.H{
-				"filename": filename,
-				"original": file.Filename,
-				"size":     file.Size,
-				"path":     filepathToFile,
-			})
+			err = backend.Put(filename, body, file.Size, backends.PutOptions{MimeType: detected, DeleteKey: deleteKey, Expiry: expiry})
+			src.Close()
+			if err != nil {
+				if release != nil {
+					release(file.Size)
+				}
+				continue
+			}
+
+			uploaded := gin.H{
+				"filename":   filename,
+				"original":   file.Filename,
+				"size":       file.Size,
+				"delete_key": deleteKey,
+			}
+			if expiry != nil {
+				uploaded["expires_at"] = expiry.Format(time.RFC3339)
+			}
+			uploadedFiles = append(uploadedFiles, uploaded)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -124,86 +655,167 @@ func main() {
 		})
 	})
 
-	// 📥 Скачивание файла
-	r.GET("/download/:filename", func(c *gin.Context) {
+	// 📤 Загрузка файла по частям (resumable upload)
+	r.POST("/upload/chunk", requireScope(authn, auth.ScopeUpload), func(c *gin.Context) {
+		handleUploadChunk(c, chunkStagingDir, backend, cfg.allowMime, cfg.denyMime, cfg.maxExpiry)
+	})
+
+	// 📤 Узнать, сколько байт уже принято для данной закачки
+	r.HEAD("/upload/chunk/:id", requireScope(authn, auth.ScopeUpload), func(c *gin.Context) {
+		handleUploadChunkHead(c, chunkStagingDir)
+	})
+
+	// downloadMiddleware защищает все маршруты, отдающие содержимое файла —
+	// /download/:filename отдаёт те же байты, что и /files/:filename, и
+	// должен требовать ту же авторизацию, иначе ScopeDownload/PublicDownload
+	// обходятся простым вызовом другого маршрута.
+	downloadMiddleware := requireScope(authn, auth.ScopeDownload)
+	if authn != nil && authn.PublicDownload() {
+		downloadMiddleware = authn.OptionalAuth()
+	}
+
+	// 📥 Скачивание файла (с поддержкой Range для докачки)
+	r.GET("/download/:filename", downloadMiddleware, func(c *gin.Context) {
 		filename := c.Param("filename")
-		filepath := filepath.Join(uploadDir, filename)
 
-		// Проверяем существование файла
-		if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		// Для бэкендов без io.ReadSeeker (например S3), но с
+		// backends.RangeGetter, Range-заголовок пересылается в backend
+		// напрямую — одним обращением, без отдельного Get только ради
+		// meta.Size/ModTime.
+		if rangeHeader := c.GetHeader("Range"); rangeHeader != "" {
+			if rg, ok := backend.(backends.RangeGetter); ok {
+				ranged, rng, err := rg.GetRange(filename, rangeHeader)
+				if err != nil {
+					if err == backends.ErrNotExist {
+						c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
+						return
+					}
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось прочитать диапазон файла"})
+					return
+				}
+				defer ranged.Close()
+				setDownloadHeaders(c, filename)
+				if rng.Partial {
+					c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.Start, rng.End, rng.Total))
+					c.Header("Content-Length", strconv.FormatInt(rng.End-rng.Start+1, 10))
+					c.Status(http.StatusPartialContent)
+				} else {
+					c.Header("Content-Length", strconv.FormatInt(rng.Total, 10))
+				}
+				io.Copy(c.Writer, ranged)
+				return
+			}
+		}
+
+		rc, meta, err := backend.Get(filename)
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
 			return
 		}
+		defer rc.Close()
+
+		setDownloadHeaders(c, filename)
 
-		// Устанавливаем заголовки для скачивания
-		c.Header("Content-Description", "File Transfer")
-		c.Header("Content-Transfer-Encoding", "binary")
-		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-		c.Header("Content-Type", "application/octet-stream")
-		c.File(filepath)
+		// http.ServeContent умеет Range-запросы, но требует io.ReadSeeker —
+		// им обладают локальные файлы.
+		if seeker, ok := rc.(io.ReadSeeker); ok {
+			http.ServeContent(c.Writer, c.Request, filename, meta.ModTime, seeker)
+			return
+		}
+
+		io.Copy(c.Writer, rc)
 	})
 
 	// 📥 Стриминг файла (без скачивания)
-	r.GET("/files/:filename", func(c *gin.Context) {
+	r.GET("/files/:filename", downloadMiddleware, func(c *gin.Context) {
 		filename := c.Param("filename")
-		filepath1 := filepath.Join(uploadDir, filename)
 
-		if _, err := os.Stat(filepath1); os.IsNotExist(err) {
+		rc, meta, err := backend.Get(filename)
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
 			return
 		}
+		defer rc.Close()
 
-		// Определяем Content-Type на основе расширения
-		ext := filepath.Ext(filename)
-		contentType := mimeTypes[ext]
+		contentType := meta.MimeType
+		if contentType == "" {
+			contentType = mimeTypes[filepath.Ext(filename)]
+		}
 		if contentType == "" {
 			contentType = "application/octet-stream"
 		}
 
+		c.Header("X-Content-Type-Options", "nosniff")
 		c.Header("Content-Type", contentType)
-		c.File(filepath1)
+		io.Copy(c.Writer, rc)
 	})
 
-	// 📋 Получение списка файлов
-	r.GET("/files", func(c *gin.Context) {
-		files, err := os.ReadDir(uploadDir)
+	// 📋 Получение списка файлов: поиск по подстроке, глобу, сортировка, пагинация
+	r.GET("/files", requireScope(authn, auth.ScopeList), func(c *gin.Context) {
+		entries, generation, builtAt := filesIndex.snapshot()
+
+		etag := fmt.Sprintf("%q", fmt.Sprintf("files-%d", generation))
+		c.Header("ETag", etag)
+		c.Header("Last-Modified", builtAt.UTC().Format(http.TimeFormat))
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		matched, err := filterFileIndex(entries, c.Query("q"), c.Query("glob"))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось прочитать директорию"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		sortFileIndex(matched, c.DefaultQuery("sort", "name"), c.DefaultQuery("order", "asc"))
 
-		var fileList []gin.H
-		for _, file := range files {
-			info, err := file.Info()
-			if err != nil {
-				continue
-			}
+		total := len(matched)
+		page := paginateFileIndex(matched, c.Query("limit"), c.Query("offset"))
 
-			fileList = append(fileList, gin.H{
-				"name":    file.Name(),
-				"size":    info.Size(),
-				"modTime": info.ModTime().Format(time.RFC3339),
-				"isDir":   file.IsDir(),
-			})
+		fileList := make([]gin.H, 0, len(page))
+		for _, file := range page {
+			entry := gin.H{
+				"name":     file.Name,
+				"size":     file.Size,
+				"modTime":  file.ModTime.Format(time.RFC3339),
+				"mimetype": file.MimeType,
+				"sha256":   file.SHA256,
+			}
+			if file.Expiry != nil {
+				entry["expiresAt"] = file.Expiry.Format(time.RFC3339)
+				entry["ttlSeconds"] = int64(time.Until(*file.Expiry).Seconds())
+			}
+			fileList = append(fileList, entry)
 		}
 
 		c.JSON(http.StatusOK, gin.H{
+			"total": total,
 			"count": len(fileList),
 			"files": fileList,
 		})
 	})
 
-	// 🗑️ Удаление файла
-	r.DELETE("/files/:filename", func(c *gin.Context) {
+	// 🗑️ Удаление файла (требует ключ, выданный при загрузке)
+	r.DELETE("/files/:filename", requireScope(authn, auth.ScopeDelete), func(c *gin.Context) {
 		filename := c.Param("filename")
-		filepath := filepath.Join(uploadDir, filename)
 
-		if _, err := os.Stat(filepath); os.IsNotExist(err) {
+		rc, meta, err := backend.Get(filename)
+		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Файл не найден"})
 			return
 		}
+		rc.Close()
+
+		if status := checkDeleteKey(meta.DeleteKey, c.GetHeader("X-Delete-Key")); status != 0 {
+			msg := "Неверный ключ удаления"
+			if status == http.StatusNotFound {
+				msg = "Требуется заголовок X-Delete-Key"
+			}
+			c.JSON(status, gin.H{"error": msg})
+			return
+		}
 
-		if err := os.Remove(filepath); err != nil {
+		if err := backend.Delete(filename); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить файл"})
 			return
 		}
@@ -212,31 +824,550 @@ func main() {
 	})
 
 	// 📊 Статус сервера
-	r.GET("/status", func(c *gin.Context) {
-		var totalSize int64
-		files, _ := os.ReadDir(uploadDir)
+	r.GET("/status", requireScope(authn, auth.ScopeAdmin), func(c *gin.Context) {
+		files, _ := backend.List()
 
+		var totalSize int64
+		var filesWithExpiry int
+		var nextExpiry *time.Time
 		for _, file := range files {
-			info, _ := file.Info()
-			totalSize += info.Size()
+			totalSize += file.Size
+			if file.Expiry != nil {
+				filesWithExpiry++
+				if nextExpiry == nil || file.Expiry.Before(*nextExpiry) {
+					nextExpiry = file.Expiry
+				}
+			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"filesCount":  len(files),
-			"totalSize":   totalSize,
-			"totalSizeMB": totalSize / (1 << 20),
-			"uploadDir":   uploadDir,
-			"serverTime":  time.Now().Format(time.RFC3339),
-		})
+		status := gin.H{
+			"filesCount":      len(files),
+			"totalSize":       totalSize,
+			"totalSizeMB":     totalSize / (1 << 20),
+			"storageBackend":  cfg.storageBackend,
+			"serverTime":      time.Now().Format(time.RFC3339),
+			"filesWithExpiry": filesWithExpiry,
+		}
+		if nextExpiry != nil {
+			status["nextExpiryAt"] = nextExpiry.Format(time.RFC3339)
+			status["nextExpiryInSeconds"] = int64(time.Until(*nextExpiry).Seconds())
+		}
+		c.JSON(http.StatusOK, status)
+	})
+
+	// 📦 Скачивание нескольких файлов одним архивом (без временных файлов на диске)
+	r.GET("/archive", requireScope(authn, auth.ScopeDownload), func(c *gin.Context) {
+		filesParam := c.Query("files")
+		if filesParam == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "не указан параметр files"})
+			return
+		}
+		names := strings.Split(filesParam, ",")
+
+		format := c.DefaultQuery("format", "zip")
+		var contentType, ext string
+		switch format {
+		case "zip":
+			contentType, ext = "application/zip", "zip"
+		case "tar.gz":
+			contentType, ext = "application/gzip", "tar.gz"
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format должен быть zip или tar.gz"})
+			return
+		}
+
+		// Проверяем все файлы до начала стриминга: после c.Status(200) клиенту
+		// уже не сообщить об ошибке иначе, чем оборвав архив посередине.
+		missing, err := firstMissingArchiveFile(backend, names)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if missing != "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("файл %q не найден", missing)})
+			return
+		}
+
+		c.Header("Content-Disposition", contentDispositionAttachment("archive."+ext))
+		c.Header("Content-Type", contentType)
+		c.Status(http.StatusOK)
+
+		pr, pw := io.Pipe()
+		go func() {
+			var err error
+			if format == "zip" {
+				err = buildZipArchive(pw, backend, names)
+			} else {
+				err = buildTarGzArchive(pw, backend, names)
+			}
+			pw.CloseWithError(err)
+		}()
+
+		if _, err := io.Copy(c.Writer, pr); err != nil {
+			log.Println("ошибка стриминга архива:", err)
+		}
 	})
 
 	// Запуск сервера
 	fmt.Println("Сервер запущен на http://localhost:8080")
-	fmt.Println("Директория для загрузок:", uploadDir)
+	fmt.Println("Бэкенд хранилища:", cfg.storageBackend)
 	r.Run(":9080")
 }
 
-// MIME типы для расширений файлов
+// chunkUploadMeta — метаданные незавершённой частичной закачки, хранятся
+// рядом с .part файлом в виде JSON-сайдкара.
+type chunkUploadMeta struct {
+	ID          string     `json:"id"`
+	Filename    string     `json:"filename"`
+	Total       int64      `json:"total"`
+	Received    int64      `json:"received"`
+	Sha256State string     `json:"sha256_state"` // base64 от сохранённого состояния hash.Hash
+	CreatedAt   time.Time  `json:"created_at"`
+	DeleteKey   string     `json:"delete_key"`
+	Expiry      *time.Time `json:"expiry,omitempty"`
+}
+
+func chunkPartPath(stagingDir, id string) string {
+	return filepath.Join(stagingDir, id+".part")
+}
+
+func chunkMetaPath(stagingDir, id string) string {
+	return filepath.Join(stagingDir, id+".part.json")
+}
+
+func loadChunkMeta(stagingDir, id string) (*chunkUploadMeta, error) {
+	data, err := os.ReadFile(chunkMetaPath(stagingDir, id))
+	if err != nil {
+		return nil, err
+	}
+	var meta chunkUploadMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
+
+func saveChunkMeta(stagingDir string, meta *chunkUploadMeta) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(chunkMetaPath(stagingDir, meta.ID), data, 0o644)
+}
+
+func newSha256State() hash.Hash {
+	return sha256.New()
+}
+
+func marshalHashState(h hash.Hash) (string, error) {
+	marshaler, ok := h.(encoding.BinaryMarshaler)
+	if !ok {
+		return "", fmt.Errorf("hash не поддерживает сохранение состояния")
+	}
+	data, err := marshaler.MarshalBinary()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func unmarshalHashState(encoded string) (hash.Hash, error) {
+	h := newSha256State()
+	if encoded == "" {
+		return h, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	unmarshaler, ok := h.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return nil, fmt.Errorf("hash не поддерживает восстановление состояния")
+	}
+	if err := unmarshaler.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// setDownloadHeaders выставляет общие заголовки ответа для /download/:filename
+// независимо от того, каким путём получен файл — целиком через backend.Get
+// или частями через backends.RangeGetter.
+func setDownloadHeaders(c *gin.Context, filename string) {
+	c.Header("Content-Description", "File Transfer")
+	c.Header("Content-Transfer-Encoding", "binary")
+	c.Header("Accept-Ranges", "bytes")
+	c.Header("Content-Disposition", contentDispositionAttachment(filename))
+	c.Header("Content-Type", "application/octet-stream")
+}
+
+// handleUploadChunk принимает один чанк файла и дописывает его в .part файл
+// на стейджинговом диске, финализируя закачку (перенос в backend), когда
+// получен последний байт.
+func handleUploadChunk(c *gin.Context, stagingDir string, backend backends.Backend, allowMime, denyMime []string, maxExpiry time.Duration) {
+	id := c.GetHeader("Upload-Id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Заголовок Upload-Id обязателен"})
+		return
+	}
+	// Защита от path traversal через Upload-Id
+	if id != filepath.Base(id) || strings.Contains(id, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный Upload-Id"})
+		return
+	}
+
+	contentRange := c.GetHeader("Content-Range")
+	if contentRange == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Заголовок Content-Range обязателен"})
+		return
+	}
+	start, end, total, err := backends.ParseContentRange(contentRange)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if start > end || end >= total {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Диапазон выходит за пределы total"})
+		return
+	}
+
+	meta, err := loadChunkMeta(stagingDir, id)
+	if err != nil {
+		// Первый чанк этой закачки
+		filename := c.GetHeader("X-Filename")
+		if filename == "" {
+			filename = id
+		}
+		expiry, err := parseFileExpiry(c.GetHeader("X-File-Expiry"), maxExpiry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		deleteKey, err := generateDeleteKey()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сгенерировать ключ удаления"})
+			return
+		}
+		meta = &chunkUploadMeta{
+			ID:        id,
+			Filename:  filepath.Base(filename),
+			Total:     total,
+			Received:  0,
+			CreatedAt: time.Now(),
+			DeleteKey: deleteKey,
+			Expiry:    expiry,
+		}
+	}
+
+	if start != meta.Received {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  "Чанк не продолжает закачку с ожидаемого смещения",
+			"offset": meta.Received,
+		})
+		return
+	}
+	if meta.Total != total {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "total не совпадает с ранее заявленным"})
+		return
+	}
+
+	partPath := chunkPartPath(stagingDir, id)
+	partFile, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось открыть файл части закачки"})
+		return
+	}
+	defer partFile.Close()
+
+	hasher, err := unmarshalHashState(meta.Sha256State)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить sha256"})
+		return
+	}
+
+	written, err := writeChunkBody(c, partFile, hasher, end-start+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось записать чанк"})
+		return
+	}
+
+	meta.Received += written
+	if meta.Sha256State, err = marshalHashState(hasher); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить состояние sha256"})
+		return
+	}
+
+	if meta.Received < meta.Total {
+		if err := saveChunkMeta(stagingDir, meta); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить метаданные"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"id":       id,
+			"received": meta.Received,
+			"total":    meta.Total,
+			"done":     false,
+		})
+		return
+	}
+
+	// Последний чанк получен — финализируем закачку
+	if meta.Received != meta.Total {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Размер полученных данных не совпадает с total"})
+		return
+	}
+	partFile.Close()
+
+	finalName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), meta.Filename)
+
+	staged, err := os.Open(partPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось открыть собранный файл"})
+		return
+	}
+	detected, body, err := sniffContentType(staged)
+	if err != nil {
+		staged.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось определить тип содержимого"})
+		return
+	}
+	if reason := validateMime(meta.Filename, detected, allowMime, denyMime); reason != "" {
+		staged.Close()
+		os.Remove(partPath)
+		os.Remove(chunkMetaPath(stagingDir, id))
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": reason})
+		return
+	}
+
+	if state, ok := auth.StateFromContext(c); ok {
+		if !state.TryReserveBytes(meta.Total) {
+			staged.Close()
+			os.Remove(partPath)
+			os.Remove(chunkMetaPath(stagingDir, id))
+			c.JSON(http.StatusForbidden, gin.H{"error": "превышена квота на объём хранимых файлов для этого ключа"})
+			return
+		}
+		defer func() {
+			if c.Writer.Status() != http.StatusOK {
+				state.ReleaseBytes(meta.Total)
+			}
+		}()
+	}
+
+	putErr := backend.Put(finalName, body, meta.Total, backends.PutOptions{
+		MimeType:  detected,
+		DeleteKey: meta.DeleteKey,
+		Expiry:    meta.Expiry,
+	})
+	staged.Close()
+	if putErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось завершить закачку"})
+		return
+	}
+
+	os.Remove(partPath)
+	os.Remove(chunkMetaPath(stagingDir, id))
+
+	response := gin.H{
+		"id":         id,
+		"filename":   finalName,
+		"size":       meta.Total,
+		"sha256":     fmt.Sprintf("%x", hasher.Sum(nil)),
+		"delete_key": meta.DeleteKey,
+		"done":       true,
+	}
+	if meta.Expiry != nil {
+		response["expires_at"] = meta.Expiry.Format(time.RFC3339)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeChunkBody копирует ровно expected байт из тела запроса в part-файл,
+// одновременно обновляя sha256 accumulator.
+func writeChunkBody(c *gin.Context, dst *os.File, hasher hash.Hash, expected int64) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var total int64
+	for total < expected {
+		toRead := int64(len(buf))
+		if remaining := expected - total; remaining < toRead {
+			toRead = remaining
+		}
+		n, err := c.Request.Body.Read(buf[:toRead])
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return total, werr
+			}
+			hasher.Write(buf[:n])
+			total += int64(n)
+		}
+		if err != nil {
+			if err.Error() == "EOF" && total == expected {
+				break
+			}
+			if total < expected {
+				return total, err
+			}
+			break
+		}
+	}
+	return total, nil
+}
+
+// handleUploadChunkHead сообщает клиенту, сколько байт уже сохранено, чтобы
+// он мог продолжить закачку с нужного смещения.
+func handleUploadChunkHead(c *gin.Context, stagingDir string) {
+	id := c.Param("id")
+	meta, err := loadChunkMeta(stagingDir, id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("X-Upload-Offset", strconv.FormatInt(meta.Received, 10))
+	c.Header("X-Upload-Total", strconv.FormatInt(meta.Total, 10))
+	c.Status(http.StatusOK)
+}
+
+// sweepStaleChunkUploads периодически удаляет брошенные .part файлы вместе
+// с их сайдкарами метаданных.
+func sweepStaleChunkUploads(stagingDir string) {
+	ticker := time.NewTicker(chunkSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		entries, err := os.ReadDir(stagingDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasSuffix(entry.Name(), ".part.json") {
+				continue
+			}
+			id := strings.TrimSuffix(entry.Name(), ".part.json")
+			meta, err := loadChunkMeta(stagingDir, id)
+			if err != nil {
+				continue
+			}
+			if time.Since(meta.CreatedAt) > chunkUploadTTL {
+				os.Remove(chunkPartPath(stagingDir, id))
+				os.Remove(chunkMetaPath(stagingDir, id))
+			}
+		}
+	}
+}
+
+// sweepExpiredFiles в стиле linx-cleanup периодически обходит все файлы в
+// backend и удаляет те, чей срок хранения истёк. Файлы, срок которых List не
+// сообщает (FileInfo.Expiry == nil — так у S3-backend'а, см. s3.List), не
+// удаляются.
+func sweepExpiredFiles(backend backends.Backend, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		files, err := backend.List()
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		for _, file := range files {
+			if file.Expiry != nil && file.Expiry.Before(now) {
+				backend.Delete(file.Key)
+			}
+		}
+	}
+}
+
+// checkDeleteKey проверяет заголовок X-Delete-Key против ключа, сохранённого
+// при загрузке файла, и возвращает HTTP-статус, которым нужно ответить:
+// 0 — ключ не требуется или совпал, 404 — файл защищён ключом, а заголовок не
+// передан, 403 — заголовок передан, но не совпадает.
+func checkDeleteKey(expectedKey, header string) int {
+	if expectedKey == "" {
+		return 0
+	}
+	if header == "" {
+		return http.StatusNotFound
+	}
+	if header != expectedKey {
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// generateDeleteKey создаёт случайный ключ, который нужно предъявить в
+// заголовке X-Delete-Key, чтобы удалить загруженный файл.
+func generateDeleteKey() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// parseFileExpiry разбирает заголовок X-File-Expiry ("never" или число секунд)
+// в абсолютное время истечения. Пустой заголовок и "never" означают, что файл
+// хранится бессрочно. Если maxExpiry задан и запрошенный срок его превышает,
+// срок обрезается до maxExpiry.
+func parseFileExpiry(header string, maxExpiry time.Duration) (*time.Time, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "never" {
+		return nil, nil
+	}
+
+	seconds, err := strconv.ParseInt(header, 10, 64)
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("некорректный X-File-Expiry: ожидается число секунд или \"never\"")
+	}
+
+	ttl := time.Duration(seconds) * time.Second
+	if maxExpiry > 0 && ttl > maxExpiry {
+		ttl = maxExpiry
+	}
+	expiry := time.Now().Add(ttl)
+	return &expiry, nil
+}
+
+// contentDispositionAttachment формирует заголовок Content-Disposition с
+// именем файла, закодированным по RFC 5987, чтобы корректно передавать
+// не-ASCII имена (filename* рядом с ASCII-совместимым filename).
+func contentDispositionAttachment(filename string) string {
+	return fmt.Sprintf("attachment; filename=%q; filename*=utf-8''%s", asciiFallbackName(filename), encodeRFC5987(filename))
+}
+
+// asciiFallbackName возвращает ASCII-совместимую версию имени файла для
+// старых клиентов, которые не понимают filename* из RFC 5987.
+func asciiFallbackName(filename string) string {
+	var b strings.Builder
+	for _, r := range filename {
+		if r < 0x80 {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func encodeRFC5987(s string) string {
+	var b strings.Builder
+	for _, r := range []byte(s) {
+		if isRFC5987Unreserved(r) {
+			b.WriteByte(r)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", r)
+		}
+	}
+	return b.String()
+}
+
+func isRFC5987Unreserved(b byte) bool {
+	return (b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') ||
+		(b >= '0' && b <= '9') ||
+		b == '-' || b == '.' || b == '_' || b == '~'
+}
+
+// MIME типы для расширений файлов (фоллбэк, если backend не знает mimetype)
 var mimeTypes = map[string]string{
 	".txt":  "text/plain",
 	".html": "text/html",
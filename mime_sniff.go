@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// extMimeExpectations сопоставляет расширение ожидаемому MIME-типу для тех
+// форматов, которые net/http.DetectContentType распознаёт надёжно. Сюда же
+// специально добавлены опасные исполняемые расширения: поскольку реальный
+// exe/dll не детектируется как "свой" тип сигнатурами stdlib, они всегда
+// будут расходиться с детектированным содержимым и отклоняться.
+var extMimeExpectations = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".pdf":  "application/pdf",
+	".zip":  "application/zip",
+	".txt":  "text/plain",
+	".html": "text/html",
+	".htm":  "text/html",
+	".exe":  "application/x-msdownload",
+	".dll":  "application/x-msdownload",
+	".bat":  "application/x-msdownload",
+	".com":  "application/x-msdownload",
+	".scr":  "application/x-msdownload",
+	".msi":  "application/x-msdownload",
+}
+
+// sniffContentType читает первые 512 байт из r, определяет MIME-тип по
+// сигнатуре (net/http.DetectContentType) и возвращает reader, в котором эти
+// байты восстановлены, чтобы дальнейшее чтение не потеряло начало файла.
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil, err
+	}
+	buf = buf[:n]
+
+	detected := normalizeMime(http.DetectContentType(buf))
+	return detected, io.MultiReader(bytes.NewReader(buf), r), nil
+}
+
+// normalizeMime отбрасывает параметры вида "; charset=utf-8"
+func normalizeMime(mimeType string) string {
+	if i := strings.IndexByte(mimeType, ';'); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+	return strings.TrimSpace(mimeType)
+}
+
+// validateMime проверяет обнаруженный тип против allow/deny списков (глобы
+// вида "image/*") и против ожидаемого по расширению типа. Возвращает
+// причину отказа или пустую строку, если загрузка допустима.
+func validateMime(filename, detected string, allowList, denyList []string) string {
+	for _, pattern := range denyList {
+		if mimeGlobMatch(pattern, detected) {
+			return "Тип содержимого запрещён: " + detected
+		}
+	}
+
+	if len(allowList) > 0 {
+		allowed := false
+		for _, pattern := range allowList {
+			if mimeGlobMatch(pattern, detected) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "Тип содержимого не входит в разрешённый список: " + detected
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if expected, ok := extMimeExpectations[ext]; ok && expected != detected {
+		return "Содержимое файла не соответствует расширению " + ext + " (обнаружено " + detected + ")"
+	}
+
+	return ""
+}
+
+func mimeGlobMatch(pattern, mimeType string) bool {
+	ok, err := path.Match(pattern, mimeType)
+	return err == nil && ok
+}
+
+// parseMimeList разбирает значение флага --allow-mime/--deny-mime
+// (список глобов через запятую) в срез, пропуская пустые элементы.
+func parseMimeList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
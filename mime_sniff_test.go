@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+var (
+	pngSignature  = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+	jpegSignature = []byte("\xFF\xD8\xFF\xE0\x00\x10JFIF\x00\x01")
+	htmlContent   = []byte("<html><body><h1>not a text file</h1></body></html>")
+	plainText     = []byte("это обычный текстовый файл без какой-либо разметки")
+	exeContent    = []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xFF\xFF\x00\x00")
+)
+
+func TestSniffContentType_DetectsKnownSignatures(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"png", pngSignature, "image/png"},
+		{"jpeg", jpegSignature, "image/jpeg"},
+		{"html", htmlContent, "text/html"},
+		{"plain text", plainText, "text/plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detected, _, err := sniffContentType(bytes.NewReader(tt.data))
+			if err != nil {
+				t.Fatalf("sniffContentType вернул ошибку: %v", err)
+			}
+			if detected != tt.want {
+				t.Fatalf("detected = %q, want %q", detected, tt.want)
+			}
+		})
+	}
+}
+
+// Содержимое, прочитанное sniffContentType для определения типа, не должно
+// теряться для последующего сохранения файла.
+func TestSniffContentType_PreservesBodyForLaterRead(t *testing.T) {
+	original := bytes.Repeat([]byte("0123456789"), 100) // больше 512 байт
+
+	_, body, err := sniffContentType(bytes.NewReader(original))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("не удалось прочитать восстановленное тело: %v", err)
+	}
+	if !bytes.Equal(got, original) {
+		t.Fatalf("восстановленное тело не совпадает с исходным (len got=%d, want=%d)", len(got), len(original))
+	}
+}
+
+func TestValidateMime_RejectsPNGRenamedAsExe(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(pngSignature))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("photo.exe", detected, nil, nil); reason == "" {
+		t.Fatal("ожидался отказ для PNG, переименованного в .exe")
+	}
+}
+
+func TestValidateMime_RejectsHTMLDisguisedAsTxt(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(htmlContent))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("note.txt", detected, nil, nil); reason == "" {
+		t.Fatal("ожидался отказ для HTML, замаскированного под .txt")
+	}
+}
+
+func TestValidateMime_RejectsRealExecutable(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(exeContent))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("tool.exe", detected, nil, nil); reason == "" {
+		t.Fatal("ожидался отказ для файла .exe, т.к. сигнатуры stdlib не распознают MZ как application/x-msdownload")
+	}
+}
+
+func TestValidateMime_AcceptsMatchingExtension(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(pngSignature))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("photo.png", detected, nil, nil); reason != "" {
+		t.Fatalf("неожиданный отказ для настоящего PNG с расширением .png: %s", reason)
+	}
+}
+
+func TestValidateMime_DenyListRejectsMatch(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(pngSignature))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("photo.png", detected, nil, []string{"image/*"}); reason == "" {
+		t.Fatal("ожидался отказ: image/* в deny-list")
+	}
+}
+
+func TestValidateMime_AllowListRejectsOthers(t *testing.T) {
+	detected, _, err := sniffContentType(bytes.NewReader(plainText))
+	if err != nil {
+		t.Fatalf("sniffContentType вернул ошибку: %v", err)
+	}
+
+	if reason := validateMime("note.txt", detected, []string{"image/*"}, nil); reason == "" {
+		t.Fatal("ожидался отказ: text/plain не входит в allow-list image/*")
+	}
+}